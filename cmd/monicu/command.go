@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// errUsage is returned when the application is run with invalid command line arguments.
+var errUsage = errors.New("usage: monicu [track <channel ID> | untrack <channel ID> | tracked | backfill-timestamps | failed | replay <event ID | all> | discard <event ID | all>]")
+
+// backfillBatchSize is the number of posts updated in a single transaction by backfill-timestamps.
+const backfillBatchSize = 10000
+
+// RunCommand runs an administrative command given on the command line against storage. Changes to tracked
+// channels are picked up by a running bot, which starts synchronizing newly tracked channels.
+func (a *app) RunCommand(args []string) error {
+	a.logger.Debug("Connecting to PostgreSQL storage.")
+	if err := a.storage.Connect(a.config.Storage.PostgresDSN); err != nil {
+		return fmt.Errorf("couldn't connect to storage: %s", err)
+	}
+	defer a.storage.Close()
+
+	switch {
+	case len(args) == 2 && (args[0] == "track" || args[0] == "untrack"):
+		c, err := model.ParseSnowflake(args[1])
+		if err != nil {
+			return errUsage
+		}
+
+		tracked := args[0] == "track"
+		if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) error {
+			if err := model.SetChannelTracked(a.ctx, tx, &model.TrackedChannel{DiscordID: c}, tracked); err != nil {
+				return err
+			}
+			return model.NotifyTrackingChanged(a.ctx, tx)
+		}); err != nil {
+			return fmt.Errorf("couldn't store tracked channel: %w", err)
+		}
+
+		if tracked {
+			a.logger.Infof("Started tracking channel %d.", c)
+		} else {
+			a.logger.Infof("Stopped tracking channel %d.", c)
+		}
+		return nil
+	case len(args) == 1 && args[0] == "tracked":
+		var chans []*model.TrackedChannel
+		if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) (err error) {
+			chans, err = model.FindTrackedChannels(a.ctx, tx)
+			return
+		}); err != nil {
+			return fmt.Errorf("couldn't find tracked channels: %w", err)
+		}
+
+		for _, c := range chans {
+			if c.GuildID.Valid {
+				fmt.Printf("%d\t%d\n", c.DiscordID, c.GuildID.Int64)
+			} else {
+				fmt.Printf("%d\t-\n", c.DiscordID)
+			}
+		}
+		return nil
+	case len(args) == 1 && args[0] == "backfill-timestamps":
+		var total int64
+		for {
+			var n int64
+			if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) (err error) {
+				n, err = model.BackfillPostCreatedAt(a.ctx, tx, backfillBatchSize)
+				return
+			}); err != nil {
+				return fmt.Errorf("couldn't backfill post timestamps: %w", err)
+			}
+			if n == 0 {
+				break
+			}
+
+			total += n
+			a.logger.Infof("Backfilled creation time of %d posts so far.", total)
+		}
+
+		a.logger.Infof("Backfilled creation time of %d posts.", total)
+		return nil
+	case len(args) == 1 && args[0] == "failed":
+		var events []*model.FailedEvent
+		if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) (err error) {
+			events, err = model.FindFailedEvents(a.ctx, tx)
+			return
+		}); err != nil {
+			return fmt.Errorf("couldn't find failed events: %w", err)
+		}
+
+		for _, e := range events {
+			fmt.Printf("%d\t%s\t%d\t%s\t%s\t%s\n", e.ID, e.Type, e.Attempts, e.FailedAt.Format(time.RFC3339), e.NextAttemptAt.Format(time.RFC3339), e.Error)
+		}
+		return nil
+	case len(args) == 2 && (args[0] == "replay" || args[0] == "discard"):
+		var ID uint64
+		all := args[1] == "all"
+		if !all {
+			var err error
+			if ID, err = strconv.ParseUint(args[1], 10, 32); err != nil {
+				return errUsage
+			}
+		}
+
+		var found bool
+		if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) (err error) {
+			switch {
+			case args[0] == "replay" && all:
+				found, err = model.ReplayFailedEvents(a.ctx, tx)
+			case args[0] == "replay":
+				found, err = model.ReplayFailedEvent(a.ctx, tx, model.ID(ID))
+			case all:
+				found, err = model.DeleteFailedEvents(a.ctx, tx)
+			default:
+				found, err = model.DeleteFailedEvent(a.ctx, tx, model.ID(ID))
+			}
+			return
+		}); err != nil {
+			return fmt.Errorf("couldn't %s failed events: %w", args[0], err)
+		}
+
+		switch {
+		case !found:
+			a.logger.Infof("No failed events to %s.", args[0])
+		case args[0] == "replay":
+			a.logger.Info("Scheduled failed events to be retried by the running bot.")
+		default:
+			a.logger.Info("Discarded failed events.")
+		}
+		return nil
+	default:
+		return errUsage
+	}
+}
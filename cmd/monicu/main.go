@@ -7,12 +7,14 @@ import (
 	"os"
 	"os/signal"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"pkg.mon.icu/monicu/internal/api"
 	"pkg.mon.icu/monicu/internal/config"
 	"pkg.mon.icu/monicu/internal/discord"
 	"pkg.mon.icu/monicu/internal/storage"
+	"pkg.mon.icu/monicu/internal/thumbnail"
 )
 
 type app struct {
@@ -24,9 +26,10 @@ type app struct {
 
 	config *config.Config
 
-	storage *storage.Storage
-	discord *discord.Discord
-	api     *api.API
+	storage    *storage.Storage
+	discord    *discord.Discord
+	api        *api.API
+	thumbnails *thumbnail.Thumbnailer
 }
 
 func newApp(ctx context.Context, lcf zap.Config, log *zap.SugaredLogger) (*app, error) {
@@ -47,10 +50,17 @@ func newApp(ctx context.Context, lcf zap.Config, log *zap.SugaredLogger) (*app,
 	a.storage = storage.NewStorage(ctx, log)
 
 	log.Debug("Initializing API struct.")
-	a.api = api.NewAPI(ctx, log, a.storage, api.NewConfig(a.config.Api.Port))
+	a.api = api.NewAPI(ctx, log, a.storage, api.NewConfig(a.config.Api.Port, a.config.Api.ShutdownTimeout, a.config.Api.QueryTimeout, a.config.Api.RouteQueryTimeouts, a.config.Thumbnails.Dir, a.config.Thumbnails.URL))
+
+	log.Debug("Initializing Thumbnailer struct.")
+	a.thumbnails = thumbnail.NewThumbnailer(ctx, log, a.storage, thumbnail.NewConfig(a.config.Thumbnails.Dir, a.config.Thumbnails.Widths, a.config.Thumbnails.Interval))
 
 	log.Debug("Initializing Discord struct.")
-	a.discord, err = discord.NewDiscord(ctx, log, a.config.Discord.Auth, discord.NewConfig(a.config.Discord.Guilds, a.config.Discord.Channels, a.config.Posts.IgnoreRegexp), a.storage)
+	deletePolicy, err := discord.ParseDeletePolicy(a.config.Discord.DeletePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load configuration: %w", err)
+	}
+	a.discord, err = discord.NewDiscord(ctx, log, a.config.Discord.Auth, discord.NewConfig(a.config.Discord.Guilds, a.config.Discord.Channels, a.config.Posts.IgnoreRegexp, a.config.Discord.CommandPrefix, a.config.Discord.ReconcileWindow, a.config.Discord.SyncWorkers, a.config.Discord.RESTConcurrency, a.config.Discord.AutoTrack.Categories, a.config.Discord.AutoTrack.NameRegexp, deletePolicy, a.config.Discord.MemberEvents, a.config.Queue.Dir, a.config.Queue.MaxAttempts, a.config.Queue.Workers), a.storage)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't initialize Discord struct: %w", err)
 	}
@@ -58,49 +68,54 @@ func newApp(ctx context.Context, lcf zap.Config, log *zap.SugaredLogger) (*app,
 	return a, nil
 }
 
-func (a *app) Run() error {
+// Run launches all application components and blocks until SIGINT is received, then shuts them down in
+// reverse order. Returned error combines the outcomes of every component shutdown, so a failure to
+// close one of them does not hide a failure of another.
+func (a *app) Run() (err error) {
 	a.logger.Debug("Connecting to PostgreSQL storage.")
 	if err := a.storage.Connect(a.config.Storage.PostgresDSN); err != nil {
 		return fmt.Errorf("couldn't connect to storage: %s", err)
 	}
-	defer func() {
-		a.logger.Debug("Closing PostgreSQL storage.")
-		if err := a.storage.Close(); err != nil {
-			a.logger.Errorf("Couldn't close storage: %s.", err)
-		}
-		a.logger.Debug("Closed PostgreSQL storage.")
-	}()
+	defer a.shutdown(&err, "PostgreSQL storage", a.storage.Close)
 	a.logger.Debug("Successfully connected to PostgreSQL storage.")
 
 	a.logger.Debug("Connecting to Discord API gateway.")
 	if err := a.discord.Connect(); err != nil {
 		return fmt.Errorf("couldn't connect to Discord: %s", err)
 	}
-	defer func() {
-		a.logger.Debug("Closing connection with Discord API gateway.")
-		if err := a.discord.Close(); err != nil {
-			a.logger.Errorf("Couldn't close Discord: %s.", err)
-		}
-		a.logger.Debug("Closed connection with Discord API gateway.")
-	}()
+	defer a.shutdown(&err, "Discord API gateway connection", a.discord.Close)
 	a.logger.Debug("Successfully connected to Discord API gateway.")
 
+	a.logger.Debug("Starting thumbnail pipeline.")
+	if err := a.thumbnails.Start(); err != nil {
+		return fmt.Errorf("couldn't start thumbnail pipeline: %s", err)
+	}
+	defer a.shutdown(&err, "thumbnail pipeline", a.thumbnails.Close)
+	a.logger.Debug("Started thumbnail pipeline.")
+
 	a.logger.Debug("Starting HTTP API server.")
-	a.api.Listen()
-	defer func() {
-		a.logger.Debug("Closing HTTP API server.")
-		if err := a.api.Close(); err != nil {
-			a.logger.Errorf("Couldn't close HTTP API server: %s.", err)
-		}
-		a.logger.Debug("Closed HTTP API server.")
-	}()
+	if err := a.api.Listen(); err != nil {
+		return fmt.Errorf("couldn't start HTTP API server: %s", err)
+	}
+	defer a.shutdown(&err, "HTTP API server", a.api.Close)
 	a.logger.Debug("Started HTTP API server.")
 
 	a.logger.Info("Launch complete. Send SIGINT to gracefully terminate.")
 	<-a.ctx.Done()
 	a.logger.Info("SIGINT received, terminating.")
 
-	return a.ctx.Err()
+	return nil
+}
+
+// shutdown closes a single application component, logging the outcome and appending a failure to err.
+func (a *app) shutdown(err *error, name string, close func() error) {
+	a.logger.Debugf("Closing %s.", name)
+	if cerr := close(); cerr != nil {
+		a.logger.Errorf("Couldn't close %s: %s.", name, cerr)
+		*err = multierr.Append(*err, fmt.Errorf("couldn't close %s: %w", name, cerr))
+		return
+	}
+	a.logger.Infof("Closed %s.", name)
 }
 
 func main() {
@@ -120,8 +135,16 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 {
+		if err := a.RunCommand(os.Args[1:]); err != nil {
+			log.Sugar().Fatalf("Command failed: %s.", err)
+		}
+		return
+	}
+
 	log.Debug("Initialization tasks complete, continuing with launch.")
 	if err := a.Run(); err != nil && !errors.Is(err, context.Canceled) {
 		log.Sugar().Fatalf("Application crashed: %s.", err)
 	}
+	log.Info("Shutdown complete.")
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/zap"
@@ -14,19 +16,36 @@ import (
 )
 
 type Config struct {
-	Port uint16
+	Port            uint16
+	ShutdownTimeout time.Duration
+	// QueryTimeout limits time a request may spend querying the storage, unless overridden for its route
+	// in RouteQueryTimeouts, which is keyed by route pattern such as /posts/:page.
+	QueryTimeout       time.Duration
+	RouteQueryTimeouts map[string]time.Duration
+	ThumbnailDir       string
+	ThumbnailURL       string
 }
 
-func NewConfig(port uint16) *Config {
-	return &Config{Port: port}
+func NewConfig(port uint16, shutdownTimeout time.Duration, queryTimeout time.Duration, routeQueryTimeouts map[string]time.Duration, thumbnailDir string, thumbnailURL string) *Config {
+	return &Config{
+		Port:               port,
+		ShutdownTimeout:    shutdownTimeout,
+		QueryTimeout:       queryTimeout,
+		RouteQueryTimeouts: routeQueryTimeouts,
+		ThumbnailDir:       thumbnailDir,
+		ThumbnailURL:       strings.TrimSuffix(thumbnailURL, "/"),
+	}
 }
 
 type API struct {
 	ctx     context.Context
 	logger  *zap.SugaredLogger
 	storage *storage.Storage
+	config  *Config
 	router  *gin.Engine
 	serv    *http.Server
+
+	postTotal totalCache
 }
 
 func NewAPI(ctx context.Context, logger *zap.SugaredLogger, storage *storage.Storage, config *Config) *API {
@@ -34,27 +53,83 @@ func NewAPI(ctx context.Context, logger *zap.SugaredLogger, storage *storage.Sto
 		ctx:     ctx,
 		logger:  logger,
 		storage: storage,
+		config:  config,
 		router:  gin.New(),
 	}
 	a.serv = &http.Server{Addr: fmt.Sprintf(":%d", config.Port), Handler: a.router}
 	a.router.Use(
-		ginzap.Ginzap(logger.Desugar(), time.RFC3339, true),
+		a.requestIDMiddleware(),
+		a.accessLogMiddleware(),
 		ginzap.RecoveryWithZap(logger.Desugar(), true),
+		compressionMiddleware("/thumbnails/"),
+		a.negotiationMiddleware("/thumbnails/"),
+		a.timeoutMiddleware(),
 	)
+	a.router.HandleMethodNotAllowed = true
+	a.router.NoRoute(func(c *gin.Context) { a.abortWithError(c, errNotFound()) })
+	a.router.NoMethod(func(c *gin.Context) { a.abortWithError(c, errMethodNotAllowed()) })
 	return a
 }
 
-func (a *API) Listen() {
+// timeoutMiddleware limits lifetime of the request context, which handlers pass to the storage, to
+// the query timeout configured for the matched route. Handlers that exceed it fail with a timeout error.
+func (a *API) timeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := a.config.RouteQueryTimeouts[c.FullPath()]
+		if !ok {
+			timeout = a.config.QueryTimeout
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func (a *API) Listen() error {
 	a.registerGetPosts()
+	a.registerGetUser()
+	a.registerGetUserReactions()
+	a.registerGetThumbnails()
+
+	l, err := net.Listen("tcp", a.serv.Addr)
+	if err != nil {
+		return err
+	}
+
 	go func() {
-		if err := a.serv.ListenAndServe(); err != nil {
+		if err := a.serv.Serve(l); err != nil {
 			if !errors.Is(err, http.ErrServerClosed) {
 				a.logger.Errorf("Server returned with error: %s.", err)
 			}
 		}
 	}()
+	return nil
 }
 
+// Close gracefully shuts the server down: listeners are closed right away so no new connections are
+// accepted, while in-flight requests are given up to Config.ShutdownTimeout to complete. Requests still
+// running after that are dropped.
 func (a *API) Close() error {
-	return a.serv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+
+	a.serv.SetKeepAlivesEnabled(false)
+	if err := a.serv.Shutdown(ctx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if err := a.serv.Close(); err != nil {
+			return err
+		}
+		return fmt.Errorf("requests did not drain in %s and were dropped", a.config.ShutdownTimeout)
+	}
+
+	return nil
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressWriter compresses everything written to the response. The compressor is only created on
+// the first write, so bodiless responses stay bodiless.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	w        compressor
+}
+
+// compressor is implemented by both gzip.Writer and flate.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.w == nil {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		if w.encoding == encodingGzip {
+			w.w = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.w, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	return w.w.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush writes out data buffered by the compressor before flushing the response.
+func (w *compressWriter) Flush() {
+	if w.w != nil {
+		_ = w.w.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Close() error {
+	if w.w == nil {
+		return nil
+	}
+	return w.w.Close()
+}
+
+// compressionMiddleware compresses responses with gzip or deflate depending on the request
+// Accept-Encoding header. Paths with any of the excluded prefixes, which serve already compressed
+// content, are left as is.
+func compressionMiddleware(excluded ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range excluded {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			_ = w.Close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from Accept-Encoding header value, preferring gzip. Codings
+// with zero quality value are treated as not accepted.
+func negotiateEncoding(header string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		rejected := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(param, " ", "")
+			if param == "q=0" || strings.HasPrefix(param, "q=0.0") && strings.Trim(param[len("q=0."):], "0") == "" {
+				rejected = true
+			}
+		}
+		if rejected {
+			continue
+		}
+
+		switch name {
+		case encodingGzip, "*":
+			gzipOK = true
+		case encodingDeflate:
+			deflateOK = true
+		}
+	}
+
+	switch {
+	case gzipOK:
+		return encodingGzip
+	case deflateOK:
+		return encodingDeflate
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompressWriterFlush(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		w := httptest.NewRecorder()
+		r := gin.New()
+		r.Use(compressionMiddleware())
+		r.GET("/", func(c *gin.Context) {
+			_, _ = c.Writer.WriteString("hello")
+			c.Writer.Flush()
+
+			// Everything written so far has to be decodable before the response is complete
+			var zr io.Reader
+			if encoding == encodingGzip {
+				var err error
+				if zr, err = gzip.NewReader(w.Body); err != nil {
+					t.Fatalf("%s: failed to read flushed response: %s", encoding, err)
+				}
+			} else {
+				zr = flate.NewReader(w.Body)
+			}
+			b := make([]byte, 5)
+			if _, err := io.ReadFull(zr, b); err != nil || string(b) != "hello" {
+				t.Errorf("%s: expected flushed %q, got %q (error %v)", encoding, "hello", b, err)
+			}
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		r.ServeHTTP(w, req)
+		if !w.Flushed {
+			t.Errorf("%s: expected response to be flushed", encoding)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"pkg.mon.icu/monicu/internal/storage/model"
 )
 
 // registerGetPosts GET /posts/:page
@@ -14,15 +16,115 @@ func (a *API) registerGetPosts() {
 		}
 
 		if err := c.ShouldBindUri(&param); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			a.abortWithError(c, errInvalidParameter("page", err))
+			return
+		}
+		if aerr := checkPage(param.Page); aerr != nil {
+			a.abortWithError(c, aerr)
+			return
+		}
+
+		sh, aerr := parseShape(c.Query("fields"), c.Query("include"))
+		if aerr != nil {
+			a.abortWithError(c, aerr)
+			return
+		}
+
+		envelope, aerr := parseEnvelope(c)
+		if aerr != nil {
+			a.abortWithError(c, aerr)
+			return
+		}
+
+		posts, t, err := a.getAllPosts(c.Request.Context(), param.Page, sh.include)
+		if err != nil {
+			a.abortWithError(c, errInternal(err))
+			return
+		}
+
+		a.renderPage(c, param.Page, len(posts), t, sh.applyPosts(posts), envelope)
+	})
+}
+
+// registerGetUser GET /users/:user
+func (a *API) registerGetUser() {
+	a.router.GET("/users/:user", func(c *gin.Context) {
+		var param struct {
+			User uint64 `uri:"user" binding:"required"`
+		}
+
+		if err := c.ShouldBindUri(&param); err != nil {
+			a.abortWithError(c, errInvalidParameter("user", err))
+			return
+		}
+
+		user, err := a.getUser(c.Request.Context(), param.User)
+		if err != nil {
+			a.abortWithError(c, errInternal(err))
+			return
+		}
+		if user == nil {
+			a.abortWithError(c, errNotFound())
 			return
 		}
 
-		if posts, err := a.getAllPosts(param.Page); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		a.render(c, http.StatusOK, user)
+	})
+}
+
+// registerGetUserReactions GET /users/:user/reactions/:page
+func (a *API) registerGetUserReactions() {
+	a.router.GET("/users/:user/reactions/:page", func(c *gin.Context) {
+		var param struct {
+			User uint64 `uri:"user" binding:"required"`
+			Page uint32 `uri:"page"`
+		}
+
+		if err := c.ShouldBindUri(&param); err != nil {
+			a.abortWithError(c, errInvalidParameter("user or page", err))
+			return
+		}
+		if aerr := checkPage(param.Page); aerr != nil {
+			a.abortWithError(c, aerr)
+			return
+		}
+
+		var emoji *model.Emoji
+		if e := c.Query("emoji"); e != "" {
+			emoji = parseEmoji(e)
+		}
+
+		sh, aerr := parseShape(c.Query("fields"), c.Query("include"))
+		if aerr != nil {
+			a.abortWithError(c, aerr)
 			return
-		} else {
-			c.JSON(http.StatusOK, posts)
 		}
+
+		envelope, aerr := parseEnvelope(c)
+		if aerr != nil {
+			a.abortWithError(c, aerr)
+			return
+		}
+
+		posts, t, err := a.getPostsReactedByUser(c.Request.Context(), param.User, emoji, param.Page, sh.include)
+		if err != nil {
+			a.abortWithError(c, errInternal(err))
+			return
+		}
+
+		a.renderPage(c, param.Page, len(posts), t, sh.applyPosts(posts), envelope)
 	})
 }
+
+// parseEmoji parses emoji query parameter, which is either a custom emoji ID or a Unicode emoji.
+func parseEmoji(s string) *model.Emoji {
+	if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return model.NewEmoji(0, model.NullableSnowflake{Int64: int64(id), Valid: true}, "")
+	}
+	return model.NewEmoji(0, model.NullableSnowflake{}, s)
+}
+
+// registerGetThumbnails GET /thumbnails/*filepath
+func (a *API) registerGetThumbnails() {
+	a.router.Static("/thumbnails", a.config.ThumbnailDir)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorCode is a stable machine-readable identifier of an API error. Codes are part of the public API
+// and must not be changed once released.
+type errorCode string
+
+const (
+	codeInvalidParameter   errorCode = "invalid_parameter"
+	codeNotFound           errorCode = "not_found"
+	codeMethodNotAllowed   errorCode = "method_not_allowed"
+	codeNotAcceptable      errorCode = "not_acceptable"
+	codeTimeout            errorCode = "timeout"
+	codeServiceUnavailable errorCode = "service_unavailable"
+	codeInternal           errorCode = "internal_error"
+)
+
+// apiError is an error returned to API clients. Message is safe to be shown publicly, while the
+// underlying cause is only logged.
+type apiError struct {
+	Status    int       `json:"-"`
+	Code      errorCode `json:"code"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"request_id,omitempty"`
+	cause     error
+}
+
+func newError(status int, code errorCode, message string, cause error) *apiError {
+	return &apiError{Status: status, Code: code, Message: message, cause: cause}
+}
+
+func (e *apiError) Error() string {
+	if e.cause != nil {
+		return string(e.Code) + ": " + e.cause.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.cause
+}
+
+func errInvalidParameter(name string, cause error) *apiError {
+	return newError(http.StatusBadRequest, codeInvalidParameter, "Invalid value of parameter "+name+".", cause)
+}
+
+func errNotFound() *apiError {
+	return newError(http.StatusNotFound, codeNotFound, "Resource not found.", nil)
+}
+
+func errMethodNotAllowed() *apiError {
+	return newError(http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed.", nil)
+}
+
+func errNotAcceptable() *apiError {
+	return newError(http.StatusNotAcceptable, codeNotAcceptable, "None of the accepted response formats is supported.", nil)
+}
+
+// errInternal maps an error returned by storage or other internal components to an API error, hiding
+// its details from the client.
+func errInternal(cause error) *apiError {
+	var ae *apiError
+	switch {
+	case errors.As(cause, &ae):
+		return ae
+	case errors.Is(cause, context.DeadlineExceeded):
+		return newError(http.StatusGatewayTimeout, codeTimeout, "Request took too long to process.", cause)
+	case errors.Is(cause, context.Canceled):
+		return newError(http.StatusServiceUnavailable, codeServiceUnavailable, "Service is shutting down.", cause)
+	default:
+		return newError(http.StatusInternalServerError, codeInternal, "An internal error occurred.", cause)
+	}
+}
+
+// abortWithError aborts the request and responds with the specified error, logging its cause.
+func (a *API) abortWithError(c *gin.Context, e *apiError) {
+	e.RequestID = requestID(c)
+	if e.Status >= http.StatusInternalServerError {
+		a.requestLogger(c).Errorf("Request failed: %s.", e)
+	} else {
+		a.requestLogger(c).Debugf("Request rejected: %s.", e)
+	}
+
+	_ = c.Error(e)
+	c.Abort()
+	a.render(c, e.Status, gin.H{"error": e})
+}
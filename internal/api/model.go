@@ -1,58 +1,401 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v4"
 	"pkg.mon.icu/monicu/internal/storage/model"
 )
 
-type imageModel struct {
+type thumbnailModel struct {
 	URL    string `json:"url"`
 	Width  uint32 `json:"width"`
 	Height uint32 `json:"height"`
-	Size   uint64 `json:"size"`
 }
 
+type imageModel struct {
+	URL        string            `json:"url"`
+	Width      uint32            `json:"width"`
+	Height     uint32            `json:"height"`
+	Size       uint64            `json:"size"`
+	Thumbnails []*thumbnailModel `json:"thumbnails"`
+}
+
+// userModel is a user with their Discord profile, where profile fields are null if it has not been
+// recorded yet. Nickname is the nickname in guild of the post the user is related to.
+type userModel struct {
+	ID            string  `json:"id"`
+	Username      *string `json:"username"`
+	Discriminator *string `json:"discriminator"`
+	AvatarURL     *string `json:"avatar_url"`
+	Nickname      *string `json:"nickname"`
+}
+
+// userDetailsModel is a user with their current nicknames and history of profile and nickname changes,
+// newest first.
+type userDetailsModel struct {
+	ID              string                `json:"id"`
+	Username        *string               `json:"username"`
+	Discriminator   *string               `json:"discriminator"`
+	AvatarURL       *string               `json:"avatar_url"`
+	Nicknames       []*nicknameModel      `json:"nicknames"`
+	History         []*profileRecordModel `json:"history"`
+	NicknameHistory []*nicknameModel      `json:"nickname_history"`
+}
+
+type profileRecordModel struct {
+	Username      string    `json:"username"`
+	Discriminator string    `json:"discriminator"`
+	AvatarURL     *string   `json:"avatar_url"`
+	RecordedAt    time.Time `json:"recorded_at"`
+}
+
+// nicknameModel is a nickname in a guild, which is null if the user has none.
+type nicknameModel struct {
+	GuildID    string    `json:"guild"`
+	Nickname   *string   `json:"nickname"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// channelModel is a channel with its metadata, where Name is null if it has not been recorded yet, and
+// Parent is ID of its category.
+type channelModel struct {
+	ID       string  `json:"id"`
+	GuildID  string  `json:"guild"`
+	Name     *string `json:"name"`
+	Topic    *string `json:"topic"`
+	NSFW     bool    `json:"nsfw"`
+	Position int     `json:"position"`
+	Parent   *string `json:"parent"`
+}
+
+// guildModel is a guild, where Name is null if it has not been recorded yet.
+type guildModel struct {
+	ID   string  `json:"id"`
+	Name *string `json:"name"`
+}
+
+// emojiModel is an emoji, where ID is empty for Unicode emojis.
+type emojiModel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type reactionModel struct {
+	Emoji *emojiModel `json:"emoji"`
+	Count uint32      `json:"count"`
+}
+
+// postModel is a post as returned by the API. Discord IDs are encoded as strings, since they don't fit
+// into a JavaScript number.
 type postModel struct {
-	ID        model.Ref `json:"id"`
-	ChannelID model.Ref `json:"channel"`
-	UserID    model.Ref `json:"user"`
+	ID        string        `json:"id"`
+	GuildID   string        `json:"guild"`
+	ChannelID string        `json:"channel"`
+	UserID    string        `json:"user"`
+	Message   string        `json:"message"`
+	CreatedAt time.Time     `json:"created_at"`
+	EditedAt  *time.Time    `json:"edited_at"`
+	URL       string        `json:"url"`
 	Images    []*imageModel `json:"images"`
 	Reactions uint32        `json:"reactions"`
+
+	// Related resources, loaded only if requested with include query parameter (see shape.)
+	user            *userModel
+	guild           *guildModel
+	channel         *channelModel
+	reactionDetails []*reactionModel
 }
 
-func (a *API) getAllPosts(page uint32) ([]*postModel, error) {
+// getAllPosts loads a page of posts along with the total number of posts, which is counted in the same
+// transaction unless a cached total is still fresh.
+func (a *API) getAllPosts(ctx context.Context, page uint32, include includeSet) ([]*postModel, total, error) {
 	var pm []*postModel
-	if err := a.storage.Begin(a.ctx, func(tx pgx.Tx) error {
+	t, cached := a.postTotal.load()
+	if err := a.storage.BeginReadOnly(ctx, func(tx pgx.Tx) error {
 		var posts []*model.Post
 		var err error
-		if posts, err = model.FindPosts(a.ctx, tx, page * 100, 100); err != nil {
+		if posts, err = model.FindPosts(ctx, tx, page*pageSize, pageSize); err != nil {
+			return err
+		}
+
+		if pm, err = a.newPostModels(ctx, tx, posts, include); err != nil || cached {
+			return err
+		}
+
+		t, err = countAllPosts(ctx, tx)
+		return err
+	}); err != nil {
+		return nil, total{}, err
+	}
+
+	if !cached {
+		a.postTotal.store(t)
+	}
+	return pm, t, nil
+}
+
+// getPostsReactedByUser loads a page of posts reacted to by the specified user, optionally only with
+// emoji, along with their total number counted in the same transaction.
+func (a *API) getPostsReactedByUser(ctx context.Context, user model.Snowflake, emoji *model.Emoji, page uint32, include includeSet) ([]*postModel, total, error) {
+	var pm []*postModel
+	var t total
+	if err := a.storage.BeginReadOnly(ctx, func(tx pgx.Tx) error {
+		var posts []*model.Post
+		var err error
+		if posts, err = model.FindPostsReactedByUser(ctx, tx, model.NewUser(0, user), emoji, page*pageSize, pageSize); err != nil {
+			return err
+		}
+
+		if pm, err = a.newPostModels(ctx, tx, posts, include); err != nil {
+			return err
+		}
+
+		t, err = countPostsReactedByUser(ctx, tx, user, emoji)
+		return err
+	}); err != nil {
+		return nil, total{}, err
+	}
+
+	return pm, t, nil
+}
+
+// newPostModels loads everything related to the specified posts, as well as the requested related
+// resources, and wraps them into postModels. Related rows are loaded for the whole page at once, so the
+// number of queries does not depend on the number of posts.
+func (a *API) newPostModels(ctx context.Context, tx pgx.Tx, posts []*model.Post, include includeSet) ([]*postModel, error) {
+	refs, err := model.FindPostsRefs(ctx, tx, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	var images map[model.Ref][]*model.Image
+	if images, err = model.FindPostsImages(ctx, tx, posts); err != nil {
+		return nil, err
+	}
+
+	var all []*model.Image
+	for _, im := range images {
+		all = append(all, im...)
+	}
+	var thumbs map[model.Ref][]*model.Thumbnail
+	if thumbs, err = model.FindImagesThumbnails(ctx, tx, all); err != nil {
+		return nil, err
+	}
+
+	var rc map[model.Ref]uint32
+	if rc, err = model.CountPostsUserReactions(ctx, tx, posts); err != nil {
+		return nil, err
+	}
+
+	pm := make([]*postModel, len(posts))
+	for i, p := range posts {
+		r, ok := refs[p.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to find references of post %d", p.DiscordID)
+		}
+
+		imm := make([]*imageModel, len(images[p.ID]))
+		for j, im := range images[p.ID] {
+			tm := make([]*thumbnailModel, len(thumbs[im.ID]))
+			for k, th := range thumbs[im.ID] {
+				tm[k] = &thumbnailModel{a.thumbnailURL(th), th.Width, th.Height}
+			}
+
+			imm[j] = &imageModel{im.URL, im.Width, im.Height, im.Size, tm}
+		}
+
+		createdAt := model.SnowflakeTime(p.DiscordID)
+		if p.CreatedAt.Valid {
+			createdAt = p.CreatedAt.Time
+		}
+		var editedAt *time.Time
+		if p.EditedAt.Valid {
+			editedAt = &p.EditedAt.Time
+		}
+
+		pm[i] = &postModel{
+			ID:        formatSnowflake(p.DiscordID),
+			GuildID:   formatSnowflake(r.GuildID),
+			ChannelID: formatSnowflake(r.ChannelID),
+			UserID:    formatSnowflake(r.UserID),
+			Message:   p.Message,
+			CreatedAt: createdAt,
+			EditedAt:  editedAt,
+			URL:       jumpURL(r.GuildID, r.ChannelID, p.DiscordID),
+			Images:    imm,
+			Reactions: rc[p.ID],
+		}
+	}
+
+	if err := includePostModels(ctx, tx, posts, refs, pm, include); err != nil {
+		return nil, err
+	}
+
+	return pm, nil
+}
+
+// includePostModels loads the requested related resources of the specified posts into their postModels.
+func includePostModels(ctx context.Context, tx pgx.Tx, posts []*model.Post, refs map[model.Ref]*model.PostRefs, pm []*postModel, include includeSet) error {
+	var users, guilds, channels []model.Snowflake
+	for _, r := range refs {
+		users, guilds, channels = append(users, r.UserID), append(guilds, r.GuildID), append(channels, r.ChannelID)
+	}
+
+	if include[includeUser] {
+		profiles, err := model.FindUserProfiles(ctx, tx, users)
+		if err != nil {
+			return err
+		}
+		var nicknames map[model.Ref]string
+		if nicknames, err = model.FindPostsNicknames(ctx, tx, posts); err != nil {
 			return err
 		}
 
-		pm = make([]*postModel, len(posts))
 		for i, p := range posts {
-			var images []*model.Image
-			if images, err = model.FindImages(a.ctx, tx, p); err != nil {
-				return err
+			id := refs[p.ID].UserID
+			pm[i].user = &userModel{ID: pm[i].UserID, Nickname: optionalString(nicknames[p.ID])}
+			if up, found := profiles[id]; found {
+				pm[i].user.Username, pm[i].user.Discriminator, pm[i].user.AvatarURL = &up.Username, &up.Discriminator, avatarURL(id, up.Avatar)
 			}
+		}
+	}
+	if include[includeGuild] {
+		names, err := model.FindGuildNames(ctx, tx, guilds)
+		if err != nil {
+			return err
+		}
 
-			imm := make([]*imageModel, len(images))
-			for j, im := range images {
-				imm[j] = &imageModel{im.URL, im.Width, im.Height, im.Size}
+		for i, p := range posts {
+			pm[i].guild = &guildModel{pm[i].GuildID, optionalString(names[refs[p.ID].GuildID])}
+		}
+	}
+	if include[includeChannel] {
+		metadata, err := model.FindChannelsMetadata(ctx, tx, channels)
+		if err != nil {
+			return err
+		}
+
+		for i, p := range posts {
+			pm[i].channel = &channelModel{ID: pm[i].ChannelID, GuildID: pm[i].GuildID}
+			if md, found := metadata[refs[p.ID].ChannelID]; found {
+				pm[i].channel.Name, pm[i].channel.Topic, pm[i].channel.NSFW, pm[i].channel.Position = &md.Name, optionalString(md.Topic), md.NSFW, md.Position
+				if md.ParentID.Valid {
+					parent := formatSnowflake(model.Snowflake(md.ParentID.Int64))
+					pm[i].channel.Parent = &parent
+				}
 			}
+		}
+	}
+	if include[includeReactions] {
+		counts, err := model.FindPostsReactionCounts(ctx, tx, posts)
+		if err != nil {
+			return err
+		}
 
-			var rc uint32
-			if rc, err = model.CountUserReactions(a.ctx, tx, p); err != nil {
-				return err
+		for i, p := range posts {
+			pm[i].reactionDetails = make([]*reactionModel, len(counts[p.ID]))
+			for j, c := range counts[p.ID] {
+				em := &emojiModel{Name: c.Emoji.Name}
+				if c.Emoji.DiscordID.Valid {
+					em.ID = formatSnowflake(model.Snowflake(c.Emoji.DiscordID.Int64))
+				}
+				pm[i].reactionDetails[j] = &reactionModel{em, c.Count}
 			}
+		}
+	}
+
+	return nil
+}
 
-			pm[i] = &postModel{p.ID, p.ChannelID, p.UserID, imm, rc}
+// getUser loads profile, nicknames and their history of the specified user, returning nil if the user is
+// not known.
+func (a *API) getUser(ctx context.Context, user model.Snowflake) (*userDetailsModel, error) {
+	var udm *userDetailsModel
+	if err := a.storage.BeginReadOnly(ctx, func(tx pgx.Tx) error {
+		u := model.NewUser(0, user)
+		if err := model.FindUser(ctx, tx, u); err != nil || u.ID == 0 {
+			return err
 		}
 
-		return nil
+		udm = &userDetailsModel{ID: formatSnowflake(user)}
+		p := &model.UserProfile{}
+		if found, err := model.FindUserProfile(ctx, tx, u, p); err != nil {
+			return err
+		} else if found {
+			udm.Username, udm.Discriminator, udm.AvatarURL = &p.Username, &p.Discriminator, avatarURL(user, p.Avatar)
+		}
+
+		history, err := model.FindUserProfileHistory(ctx, tx, u)
+		if err != nil {
+			return err
+		}
+		udm.History = make([]*profileRecordModel, len(history))
+		for i, r := range history {
+			udm.History[i] = &profileRecordModel{r.Username, r.Discriminator, avatarURL(user, r.Avatar), r.RecordedAt}
+		}
+
+		if udm.Nicknames, err = findNicknameModels(ctx, tx, u, model.FindNicknames); err != nil {
+			return err
+		}
+		udm.NicknameHistory, err = findNicknameModels(ctx, tx, u, model.FindNicknameHistory)
+		return err
 	}); err != nil {
 		return nil, err
 	}
 
-	return pm, nil
-}
\ No newline at end of file
+	return udm, nil
+}
+
+func findNicknameModels(ctx context.Context, tx pgx.Tx, u *model.User, find func(context.Context, pgx.Tx, *model.User) ([]*model.Nickname, error)) ([]*nicknameModel, error) {
+	nicknames, err := find(ctx, tx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	nm := make([]*nicknameModel, len(nicknames))
+	for i, n := range nicknames {
+		nm[i] = &nicknameModel{formatSnowflake(n.GuildID), optionalString(n.Nickname), n.RecordedAt}
+	}
+	return nm, nil
+}
+
+// avatarURL returns URL of the specified avatar of a user, or nil for the default avatar.
+func avatarURL(user model.Snowflake, avatar string) *string {
+	if avatar == "" {
+		return nil
+	}
+
+	u := discordgo.EndpointUserAvatar(formatSnowflake(user), avatar)
+	if strings.HasPrefix(avatar, "a_") {
+		u = discordgo.EndpointUserAvatarAnimated(formatSnowflake(user), avatar)
+	}
+	return &u
+}
+
+// optionalString returns nil for an empty string.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// thumbnailURL returns public URL of the specified thumbnail served by registerGetThumbnails.
+func (a *API) thumbnailURL(th *model.Thumbnail) string {
+	return a.config.ThumbnailURL + "/" + th.Path
+}
+
+func formatSnowflake(s model.Snowflake) string {
+	return strconv.FormatUint(s, 10)
+}
+
+// jumpURL returns URL that opens the specified message in Discord client.
+func jumpURL(guildID, channelID, messageID model.Snowflake) string {
+	return fmt.Sprintf("https://discord.com/channels/%d/%d/%d", guildID, channelID, messageID)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+const (
+	// pageSize is the number of items in a page of every paginated endpoint.
+	pageSize = 100
+
+	// exactCountThreshold is the estimated number of posts up to which they are counted exactly, larger
+	// tables are only estimated.
+	exactCountThreshold = 100000
+
+	// totalCacheTTL is how long the total number of posts is cached for.
+	totalCacheTTL = time.Minute
+)
+
+// total is the total number of items of a paginated endpoint.
+type total struct {
+	count     uint64
+	estimated bool
+}
+
+func (t total) pages() uint64 {
+	return (t.count + pageSize - 1) / pageSize
+}
+
+// totalCache caches total number of items, as counting large tables is expensive.
+type totalCache struct {
+	mu      sync.Mutex
+	total   total
+	expires time.Time
+}
+
+// load returns the cached total, or false if it expired.
+func (tc *totalCache) load() (total, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return tc.total, time.Now().Before(tc.expires)
+}
+
+// store caches the total for totalCacheTTL.
+func (tc *totalCache) store(t total) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.total, tc.expires = t, time.Now().Add(totalCacheTTL)
+}
+
+type pageMetaModel struct {
+	Page           uint32 `json:"page"`
+	PerPage        uint32 `json:"per_page"`
+	Pages          uint64 `json:"pages"`
+	Total          uint64 `json:"total"`
+	TotalEstimated bool   `json:"total_estimated"`
+}
+
+type pageLinksModel struct {
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last"`
+}
+
+// envelopeModel wraps a page of items with pagination metadata if requested with envelope query
+// parameter.
+type envelopeModel struct {
+	Data  interface{}     `json:"data"`
+	Meta  *pageMetaModel  `json:"meta"`
+	Links *pageLinksModel `json:"links"`
+}
+
+// maxPage is the largest page number, whose offset still fits into uint32.
+const maxPage = math.MaxUint32 / pageSize
+
+// checkPage rejects page numbers past maxPage.
+func checkPage(page uint32) *apiError {
+	if page > maxPage {
+		return errInvalidParameter("page", fmt.Errorf("page must be at most %d", maxPage))
+	}
+	return nil
+}
+
+// parseEnvelope parses envelope query parameter.
+func parseEnvelope(c *gin.Context) (bool, *apiError) {
+	envelope, err := strconv.ParseBool(c.DefaultQuery("envelope", "false"))
+	if err != nil {
+		return false, errInvalidParameter("envelope", err)
+	}
+	return envelope, nil
+}
+
+// renderPage renders a page of n items, setting X-Total-Count and Link headers and wrapping data into
+// envelopeModel if requested.
+func (a *API) renderPage(c *gin.Context, page uint32, n int, t total, data interface{}, envelope bool) {
+	links := newPageLinks(c.Request.URL, page, n, t)
+
+	c.Header("X-Total-Count", strconv.FormatUint(t.count, 10))
+	if t.estimated {
+		c.Header("X-Total-Count-Estimated", "true")
+	}
+	c.Header("Link", links.header())
+
+	if !envelope {
+		a.render(c, http.StatusOK, data)
+		return
+	}
+
+	a.render(c, http.StatusOK, &envelopeModel{
+		Data:  data,
+		Meta:  &pageMetaModel{page, pageSize, t.pages(), t.count, t.estimated},
+		Links: links,
+	})
+}
+
+// newPageLinks builds links to other pages of the current request, assuming page number is the last
+// path segment. Next link is omitted if the current page is known to be the last one.
+func newPageLinks(u *url.URL, page uint32, n int, t total) *pageLinksModel {
+	link := func(p uint64) string {
+		l := *u
+		l.Path = path.Join(path.Dir(u.Path), strconv.FormatUint(p, 10))
+		l.RawPath = ""
+		return l.RequestURI()
+	}
+
+	var last uint64
+	if t.pages() > 0 {
+		last = t.pages() - 1
+	}
+
+	pl := &pageLinksModel{First: link(0), Last: link(last)}
+	if page > 0 {
+		pl.Prev = link(uint64(page) - 1)
+	}
+	if n == pageSize && (t.estimated || uint64(page) < last) {
+		pl.Next = link(uint64(page) + 1)
+	}
+	return pl
+}
+
+// header formats links as Link header value.
+func (pl *pageLinksModel) header() string {
+	links := make([]string, 0, 4)
+	for _, l := range []struct{ rel, url string }{{"first", pl.First}, {"prev", pl.Prev}, {"next", pl.Next}, {"last", pl.Last}} {
+		if l.url != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, l.url, l.rel))
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// countAllPosts returns the total number of posts, which is estimated for large tables.
+func countAllPosts(ctx context.Context, tx pgx.Tx) (total, error) {
+	var t total
+	var err error
+	if t.count, err = model.EstimatePosts(ctx, tx); err != nil {
+		return total{}, err
+	}
+	if t.count >= exactCountThreshold {
+		t.estimated = true
+		return t, nil
+	}
+
+	if t.count, err = model.CountPosts(ctx, tx); err != nil {
+		return total{}, err
+	}
+	return t, nil
+}
+
+func countPostsReactedByUser(ctx context.Context, tx pgx.Tx, user model.Snowflake, emoji *model.Emoji) (total, error) {
+	count, err := model.CountPostsReactedByUser(ctx, tx, model.NewUser(0, user), emoji)
+	if err != nil {
+		return total{}, err
+	}
+	return total{count: count}, nil
+}
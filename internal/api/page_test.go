@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestTotalCache(t *testing.T) {
+	var tc totalCache
+	if _, fresh := tc.load(); fresh {
+		t.Fatal("expected empty cache to be expired")
+	}
+
+	tc.store(total{42, true})
+	if got, fresh := tc.load(); !fresh || got != (total{42, true}) {
+		t.Errorf("expected fresh total 42, got %+v (fresh %t)", got, fresh)
+	}
+
+	tc.expires = time.Now().Add(-time.Second)
+	if _, fresh := tc.load(); fresh {
+		t.Error("expected cache to expire")
+	}
+}
+
+func TestPageOverflowRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(context.Background(), zap.NewNop().Sugar(), nil, NewConfig(0, time.Second, time.Second, nil, t.TempDir(), ""))
+	a.registerGetPosts()
+	a.registerGetUserReactions()
+	for _, path := range []string{"/posts/42949673", "/users/1/reactions/42949673", "/users/1/reactions/4294967295"} {
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"invalid_parameter"`) {
+			t.Errorf("%s: expected invalid_parameter, got %d %s", path, w.Code, w.Body)
+		}
+	}
+}
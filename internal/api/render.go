@@ -0,0 +1,155 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	mimeMsgPack  = "application/msgpack"
+	mimeXMsgPack = "application/x-msgpack"
+	mimeCBOR     = "application/cbor"
+)
+
+var (
+	msgPackHandle = &codec.MsgpackHandle{WriteExt: true}
+	cborHandle    = &codec.CborHandle{}
+
+	// offeredFormats lists response formats in order of preference, the first one being the default for
+	// requests without Accept header.
+	offeredFormats = []string{binding.MIMEJSON, mimeMsgPack, mimeXMsgPack, mimeCBOR}
+)
+
+// codecRender renders data using one of the binary encodings implemented by the codec package. Field
+// names are taken from json struct tags, so all formats share the same structure.
+type codecRender struct {
+	handle      codec.Handle
+	contentType string
+	data        interface{}
+}
+
+func (r codecRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return codec.NewEncoder(w, r.handle).Encode(r.data)
+}
+
+func (r codecRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", r.contentType)
+}
+
+// negotiateFormat picks the response format based on request Accept header, returning an empty string
+// if none of the accepted formats is offered.
+func negotiateFormat(c *gin.Context) string {
+	return negotiate(c.GetHeader("Accept"), offeredFormats)
+}
+
+// mediaRange is a media type accepted by the client, which can be a wildcard such as */* or image/*,
+// along with its quality.
+type mediaRange struct {
+	typ string
+	q   float64
+}
+
+// specificity is 0 for */*, 1 for ranges such as image/* and 2 for media types.
+func (r mediaRange) specificity() int {
+	switch {
+	case r.typ == "*/*":
+		return 0
+	case strings.HasSuffix(r.typ, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matches checks if the range includes media type typ.
+func (r mediaRange) matches(typ string) bool {
+	return r.typ == "*/*" || r.typ == typ || strings.HasSuffix(r.typ, "/*") && strings.HasPrefix(typ, r.typ[:len(r.typ)-1])
+}
+
+// negotiate returns the first offered media type included in the range of Accept header value accept
+// with the highest quality, more specific ranges first, the first offered one if accept is empty, or an
+// empty string if none is acceptable. Unlike gin's NegotiateFormat, which panics on accepted types
+// longer than an offered one, media types are matched as a whole, and the ones with quality of 0 are not
+// acceptable even if they are included in another range.
+func negotiate(accept string, offered []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+
+	var ranges []mediaRange
+	excluded := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{typ: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				r.q = q
+			}
+		}
+		if r.q == 0 {
+			excluded[r.typ] = true
+		} else if r.typ != "" {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	for _, r := range ranges {
+		for _, typ := range offered {
+			if r.matches(typ) && !excluded[typ] {
+				return typ
+			}
+		}
+	}
+	return ""
+}
+
+// render writes data in the format negotiated with the client. Handlers must use it instead of c.JSON.
+func (a *API) render(c *gin.Context, code int, data interface{}) {
+	c.Writer.Header().Add("Vary", "Accept")
+	switch negotiateFormat(c) {
+	case mimeMsgPack, mimeXMsgPack:
+		c.Render(code, codecRender{msgPackHandle, mimeMsgPack, data})
+	case mimeCBOR:
+		c.Render(code, codecRender{cborHandle, mimeCBOR, data})
+	default:
+		c.JSON(code, data)
+	}
+}
+
+// negotiationMiddleware rejects requests that accept none of the offered formats before they are
+// handled, in which case the error itself is rendered in the default format. Paths with any of the
+// excluded prefixes, which serve files, are left as is.
+func (a *API) negotiationMiddleware(excluded ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range excluded {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		if negotiateFormat(c) == "" {
+			a.abortWithError(c, errNotAcceptable())
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"application/msgpack", "application/msgpack"},
+		{"application/x-msgpack", "application/x-msgpack"},
+		{"application/cbor, application/json", "application/cbor"},
+		{"Application/CBOR", "application/cbor"},
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"*/*, application/cbor", "application/cbor"},
+		{"application/json;q=0.5, application/cbor", "application/cbor"},
+		{"application/json; q=0.9, application/msgpack; q=0.95", "application/msgpack"},
+		{"text/html, application/xhtml+xml, */*;q=0.8", "application/json"},
+		{"application/json;q=0", ""},
+		{"application/json;q=0, */*", "application/msgpack"},
+		{"application/json;q=oops", ""},
+		{"application/json-seq", ""},
+		{"application/js", ""},
+		{"application", ""},
+		{"text/*", ""},
+		{"image/png, text/html", ""},
+		{",;", ""},
+	} {
+		if got := negotiate(tt.accept, offeredFormats); got != tt.want {
+			t.Errorf("Accept %q: expected %q, got %q", tt.accept, tt.want, got)
+		}
+	}
+}
+
+func TestNegotiationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(context.Background(), zap.NewNop().Sugar(), nil, NewConfig(0, time.Second, time.Second, nil, t.TempDir(), ""))
+	for _, tt := range []struct {
+		accept string
+		status int
+	}{
+		// Path does not exist, so acceptable requests are not found
+		{"application/json", http.StatusNotFound},
+		{"application/json-seq", http.StatusNotAcceptable},
+		{"text/html", http.StatusNotAcceptable},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/none", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("Accept %q: expected status %d, got %d", tt.accept, tt.status, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("Accept %q: expected error rendered as JSON, got %q", tt.accept, ct)
+		}
+	}
+}
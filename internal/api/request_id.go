@@ -0,0 +1,97 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/logging"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+	loggerKey       = "logger"
+
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware assigns every request an ID, reusing a valid X-Request-ID request header (such as
+// one set by a reverse proxy) or generating a random one. The ID is returned in X-Request-ID response
+// header and in error bodies. A logger annotated with the ID is stored both in gin context and in the
+// request context, from where it is picked up by the storage.
+func (a *API) requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		l := a.logger.With("request_id", id)
+		c.Set(requestIDKey, id)
+		c.Set(loggerKey, l)
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), l))
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// accessLogMiddleware logs every served request with the request-scoped logger.
+func (a *API) accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		l := a.requestLogger(c).With(
+			"status", c.Writer.Status(),
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"query", c.Request.URL.RawQuery,
+			"ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+			"latency", time.Since(start),
+		)
+		if len(c.Errors) > 0 {
+			l.Infow("Served request.", "errors", c.Errors.String())
+		} else {
+			l.Infow("Served request.")
+		}
+	}
+}
+
+// isValidRequestID checks that a client-supplied request ID is short and consists of printable ASCII
+// characters only, so it is safe to be logged and echoed back.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns ID of the specified request assigned by requestIDMiddleware.
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// requestLogger returns logger of the specified request assigned by requestIDMiddleware.
+func (a *API) requestLogger(c *gin.Context) *zap.SugaredLogger {
+	if l, ok := c.Get(loggerKey); ok {
+		return l.(*zap.SugaredLogger)
+	}
+	return a.logger
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Related resources that can be expanded inline with include query parameter. Every expansion is set as
+// the post field named by includeFields, replacing the ID of user, guild and channel, while reactions are
+// added next to their count.
+const (
+	includeUser      = "user"
+	includeGuild     = "guild"
+	includeChannel   = "channel"
+	includeReactions = "reactions"
+)
+
+var (
+	includeTypes = map[string]reflect.Type{
+		includeUser:      reflect.TypeOf(&userModel{}),
+		includeGuild:     reflect.TypeOf(&guildModel{}),
+		includeChannel:   reflect.TypeOf(&channelModel{}),
+		includeReactions: reflect.TypeOf([]*reactionModel{}),
+	}
+	includeFields = map[string]string{
+		includeUser:      "user",
+		includeGuild:     "guild",
+		includeChannel:   "channel",
+		includeReactions: "reaction_details",
+	}
+
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// includeSet is a set of related resources to be expanded.
+type includeSet map[string]bool
+
+// fieldTree is a set of selected fields, where each field maps to a tree of its selected subfields or
+// nil if the field is selected as a whole.
+type fieldTree map[string]fieldTree
+
+// shape describes how responses of posts endpoints are shaped by fields and include query parameters.
+type shape struct {
+	fields  fieldTree
+	include includeSet
+}
+
+// parseShape parses and validates fields and include query parameters. Field paths are dot-separated
+// and validated against the structure of postModel with expansions applied.
+func parseShape(fields, include string) (*shape, *apiError) {
+	s := &shape{include: includeSet{}}
+	if include != "" {
+		for _, name := range strings.Split(include, ",") {
+			if _, ok := includeTypes[name]; !ok {
+				return nil, errInvalidParameter("include", fmt.Errorf("unknown related resource %q", name))
+			}
+			s.include[name] = true
+		}
+	}
+
+	if fields != "" {
+		s.fields = fieldTree{}
+		for _, path := range strings.Split(fields, ",") {
+			if err := s.validatePath(path); err != nil {
+				return nil, errInvalidParameter("fields", err)
+			}
+			s.fields.add(strings.Split(path, "."))
+		}
+	}
+
+	return s, nil
+}
+
+// validatePath checks that every element of a dot-separated path names a field of the response. Fields
+// of expansions are valid only if they were included.
+func (s *shape) validatePath(path string) error {
+	t := reflect.TypeOf(postModel{})
+	for i, name := range strings.Split(path, ".") {
+		if name == "" {
+			return errors.New("empty field name")
+		}
+
+		if it, ok := s.expansionType(name); ok && i == 0 {
+			t = it
+			continue
+		}
+		ft, ok := jsonFields(elem(t))[name]
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		t = ft
+	}
+
+	return nil
+}
+
+// expansionType returns type of the post field with the specified name if it is set by an included
+// expansion.
+func (s *shape) expansionType(field string) (reflect.Type, bool) {
+	for name := range s.include {
+		if includeFields[name] == field {
+			return includeTypes[name], true
+		}
+	}
+	return nil, false
+}
+
+func (f fieldTree) add(path []string) {
+	sub, seen := f[path[0]]
+	switch {
+	case len(path) == 1:
+		f[path[0]] = nil
+	case seen && sub == nil: // already selected as a whole
+	default:
+		if sub == nil {
+			sub = fieldTree{}
+			f[path[0]] = sub
+		}
+		sub.add(path[1:])
+	}
+}
+
+// isDefault reports whether the shape leaves responses unchanged.
+func (s *shape) isDefault() bool {
+	return s.fields == nil && len(s.include) == 0
+}
+
+// applyPosts shapes a list of posts. Posts are returned unchanged if neither fields nor include were
+// specified.
+func (s *shape) applyPosts(posts []*postModel) interface{} {
+	if s.isDefault() {
+		return posts
+	}
+
+	shaped := make([]interface{}, len(posts))
+	for i, p := range posts {
+		m := toValue(reflect.ValueOf(p)).(map[string]interface{})
+		if s.include[includeUser] {
+			m[includeFields[includeUser]] = toValue(reflect.ValueOf(p.user))
+		}
+		if s.include[includeGuild] {
+			m[includeFields[includeGuild]] = toValue(reflect.ValueOf(p.guild))
+		}
+		if s.include[includeChannel] {
+			m[includeFields[includeChannel]] = toValue(reflect.ValueOf(p.channel))
+		}
+		if s.include[includeReactions] {
+			m[includeFields[includeReactions]] = toValue(reflect.ValueOf(p.reactionDetails))
+		}
+		shaped[i] = s.fields.filter(m)
+	}
+
+	return shaped
+}
+
+// filter removes fields that are not selected from values produced by toValue.
+func (f fieldTree) filter(v interface{}) interface{} {
+	if f == nil {
+		return v
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(f))
+		for name, sub := range f {
+			if fv, ok := v[name]; ok {
+				m[name] = sub.filter(fv)
+			}
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = f.filter(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// toValue converts structs to maps keyed by json field names, so they can be filtered and extended,
+// leaving values that marshal themselves (such as time.Time) intact.
+func toValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toValue(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = toValue(v.Index(i))
+		}
+		return s
+	case reflect.Struct:
+		if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			if name, ok := jsonName(v.Type().Field(i)); ok {
+				m[name] = toValue(v.Field(i))
+			}
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// jsonFields returns types of fields of the specified struct type keyed by json field names.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	if t.Kind() != reflect.Struct || t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonName(t.Field(i)); ok {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false // unexported
+	}
+
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return name, true
+	}
+}
+
+// elem dereferences pointer and slice types down to the element type.
+func elem(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
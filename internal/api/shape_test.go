@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestParseShape(t *testing.T) {
+	for _, tt := range []struct {
+		fields  string
+		include string
+		valid   bool
+	}{
+		{"", "", true},
+		{"id,images.thumbnails.url,reactions", "", true},
+		{"reaction_details", "", false},
+		{"reactions.count", "reactions", false},
+		{"reactions,reaction_details.emoji.name", "reactions", true},
+		{"user.username", "", false},
+		{"user.username", "user", true},
+		{"images.reaction_details", "reactions", false},
+		{"id,", "", false},
+		{"", "user,channel,reactions", true},
+		{"", "reaction_details", false},
+	} {
+		if _, err := parseShape(tt.fields, tt.include); (err == nil) != tt.valid {
+			t.Errorf("fields %q, include %q: expected valid %t, got %v", tt.fields, tt.include, tt.valid, err)
+		}
+	}
+}
+
+func TestApplyPostsReactionDetails(t *testing.T) {
+	s, err := parseShape("id,reactions,reaction_details.count", "reactions")
+	if err != nil {
+		t.Fatalf("failed to parse shape: %s", err)
+	}
+
+	p := &postModel{ID: "1", Message: "post", Reactions: 3, reactionDetails: []*reactionModel{{&emojiModel{Name: "👍"}, 2}, {&emojiModel{ID: "5", Name: "pog"}, 1}}}
+	shaped := s.applyPosts([]*postModel{p}).([]interface{})
+	m := shaped[0].(map[string]interface{})
+	if len(m) != 3 || m["id"] != "1" || m["reactions"] != uint32(3) {
+		t.Errorf("expected id and reactions count to be kept, got %v", m)
+	}
+
+	details := m["reaction_details"].([]interface{})
+	if len(details) != 2 {
+		t.Fatalf("expected 2 reaction details, got %v", details)
+	}
+	for i, want := range []uint32{2, 1} {
+		d := details[i].(map[string]interface{})
+		if len(d) != 1 || d["count"] != want {
+			t.Errorf("expected only count %d at %d, got %v", want, i, d)
+		}
+	}
+}
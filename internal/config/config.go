@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
@@ -13,9 +15,25 @@ import (
 
 type Config struct {
 	Discord struct {
-		Auth     string
-		Guilds   []model.Snowflake
-		Channels []model.Snowflake
+		Auth            string
+		Guilds          []model.Snowflake
+		Channels        []model.Snowflake
+		CommandPrefix   string
+		ReconcileWindow time.Duration
+		SyncWorkers     int
+		RESTConcurrency int
+		AutoTrack       struct {
+			Categories []model.Snowflake
+			NameRegexp *regexp.Regexp
+		}
+		DeletePolicy string
+		MemberEvents bool
+	}
+
+	Queue struct {
+		Dir         string
+		MaxAttempts int
+		Workers     int
 	}
 
 	Posts struct {
@@ -31,7 +49,17 @@ type Config struct {
 	}
 
 	Api struct {
-		Port uint16
+		Port               uint16
+		ShutdownTimeout    time.Duration
+		QueryTimeout       time.Duration
+		RouteQueryTimeouts map[string]time.Duration
+	}
+
+	Thumbnails struct {
+		Dir      string
+		URL      string
+		Widths   []uint32
+		Interval time.Duration
 	}
 }
 
@@ -39,6 +67,7 @@ func Read() (*Config, error) {
 	v := viper.New()
 	configureEnv(v)
 	configureLocation(v)
+	configureDefaults(v)
 	return readUnmarshalConfig(v)
 }
 
@@ -54,15 +83,45 @@ func configureLocation(v *viper.Viper) {
 	v.AddConfigPath(".")
 }
 
+func configureDefaults(v *viper.Viper) {
+	v.SetDefault("discord.commandprefix", "!monicu ")
+	v.SetDefault("discord.reconcilewindow", 72*time.Hour)
+	v.SetDefault("discord.syncworkers", 2)
+	v.SetDefault("discord.restconcurrency", 4)
+	v.SetDefault("discord.deletepolicy", "archive")
+	v.SetDefault("queue.dir", "queue")
+	v.SetDefault("queue.maxattempts", 5)
+	v.SetDefault("queue.workers", 4)
+	v.SetDefault("api.shutdowntimeout", 15*time.Second)
+	v.SetDefault("api.querytimeout", 10*time.Second)
+	v.SetDefault("thumbnails.dir", "thumbnails")
+	v.SetDefault("thumbnails.widths", []uint32{320, 640})
+	v.SetDefault("thumbnails.interval", time.Minute)
+}
+
+// validate checks values that would make components fail at runtime instead of on startup.
+func validate(c *Config) error {
+	if c.Thumbnails.Dir == "" {
+		return errors.New("thumbnails.dir must not be empty")
+	}
+	if c.Thumbnails.Interval <= 0 {
+		return errors.New("thumbnails.interval must be positive")
+	}
+	return nil
+}
+
 func readUnmarshalConfig(v *viper.Viper) (*Config, error) {
 	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
 	c := &Config{}
 	if err := v.Unmarshal(c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
-		hook.Regexp(), hook.Level(),
+		hook.Regexp(), hook.Level(), mapstructure.StringToTimeDurationHookFunc(),
 	))); err != nil {
 		return nil, err
 	}
+	if err := validate(c); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
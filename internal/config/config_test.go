@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestThumbnailDefaults(t *testing.T) {
+	v := viper.New()
+	configureDefaults(v)
+	c := &Config{}
+	if err := v.Unmarshal(c); err != nil {
+		t.Fatalf("failed to unmarshal config: %s", err)
+	}
+
+	if c.Thumbnails.Dir != "thumbnails" {
+		t.Errorf("expected default directory thumbnails, got %q", c.Thumbnails.Dir)
+	}
+	if want := []uint32{320, 640}; !reflect.DeepEqual(c.Thumbnails.Widths, want) {
+		t.Errorf("expected default widths %v, got %v", want, c.Thumbnails.Widths)
+	}
+	if c.Thumbnails.Interval != time.Minute {
+		t.Errorf("expected default interval 1m, got %s", c.Thumbnails.Interval)
+	}
+	if err := validate(c); err != nil {
+		t.Errorf("expected defaults to be valid, got %s", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		dir      string
+		interval time.Duration
+		valid    bool
+	}{
+		{"valid", "thumbnails", time.Second, true},
+		{"no directory", "", time.Second, false},
+		{"zero interval", "thumbnails", 0, false},
+		{"negative interval", "thumbnails", -time.Second, false},
+	} {
+		c := &Config{}
+		c.Thumbnails.Dir, c.Thumbnails.Interval = tt.dir, tt.interval
+		if err := validate(c); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %t, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+const (
+	defaultTopPosters = 5
+	maxTopPosters     = 20
+)
+
+// commandRequest is an invocation of a bot command independent of how it was issued, so the same
+// commands can be served both as prefix commands and as slash commands.
+type commandRequest struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	Args      []string
+}
+
+// command is a bot command. Its handler returns text of the reply.
+type command struct {
+	Name        string
+	Usage       string
+	Description string
+	Handler     func(r *commandRequest) (string, error)
+}
+
+// errCommandUsage is returned by command handlers when command was invoked with invalid arguments.
+var errCommandUsage = errors.New("invalid command usage")
+
+func (d *Discord) commands() []*command {
+	return []*command{
+		{"help", "help", "Lists available commands.", d.commandHelp},
+		{"top", "top [count]", "Shows users with the most posts.", d.commandTop},
+		{"posts", "posts [user]", "Shows number of posts of a user, yourself by default.", d.commandPosts},
+		{"best", "best", "Shows the most reacted post in this channel.", d.commandBest},
+		{"sync", "sync", "Shows progress of channel synchronization and failures of event handlers.", d.commandSync},
+		{"track", "track [channel]", "Starts tracking a channel, this one by default. Requires Manage Server permission.", d.commandTrack},
+		{"untrack", "untrack [channel]", "Stops tracking a channel, this one by default. Requires Manage Server permission.", d.commandUntrack},
+	}
+}
+
+func (d *Discord) findCommand(name string) *command {
+	for _, c := range d.commands() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// isCommand checks if message content starts with the configured command prefix. Prefix with trailing
+// whitespace, such as "!monicu ", also matches messages consisting of the prefix alone.
+func (d *Discord) isCommand(m *discordgo.Message) bool {
+	p := d.config.commandPrefix
+	return strings.TrimSpace(p) != "" && (strings.HasPrefix(m.Content, p) || m.Content == strings.TrimSpace(p))
+}
+
+// handlePrefixCommand parses a prefix command from message and replies to it.
+func (d *Discord) handlePrefixCommand(m *discordgo.Message) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(m.Content, strings.TrimSpace(d.config.commandPrefix)))
+	if len(fields) == 0 {
+		fields = []string{"help"}
+	}
+
+	r := &commandRequest{GuildID: m.GuildID, ChannelID: m.ChannelID, UserID: m.Author.ID, Args: fields[1:]}
+	reply := d.runCommand(fields[0], r)
+	// Replies mention users, who should not be notified of being listed
+	if _, err := d.session.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:         reply,
+		Reference:       m.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+	}); err != nil {
+		d.logger.Errorf("Failed to reply to command %s: %s.", m.ID, err)
+	}
+}
+
+// runCommand runs command with the specified name and returns text of the reply.
+func (d *Discord) runCommand(name string, r *commandRequest) string {
+	c := d.findCommand(strings.ToLower(name))
+	if c == nil {
+		return fmt.Sprintf("Unknown command `%s`, try `%shelp`.", name, d.config.commandPrefix)
+	}
+
+	d.logger.Infof("Running command %s for user %s in channel %s.", c.Name, r.UserID, d.describeID(r.ChannelID))
+	reply, err := c.Handler(r)
+	if err != nil {
+		if errors.Is(err, errCommandUsage) {
+			return fmt.Sprintf("Usage: `%s%s`.", d.config.commandPrefix, c.Usage)
+		}
+		d.logger.Errorf("Failed to run command %s: %s.", c.Name, err)
+		return "Something went wrong, please try again later."
+	}
+
+	return reply
+}
+
+func (d *Discord) commandHelp(*commandRequest) (string, error) {
+	var b strings.Builder
+	b.WriteString("Available commands:")
+	for _, c := range d.commands() {
+		fmt.Fprintf(&b, "\n`%s%s` — %s", d.config.commandPrefix, c.Usage, c.Description)
+	}
+	return b.String(), nil
+}
+
+func (d *Discord) commandTop(r *commandRequest) (string, error) {
+	n := uint64(defaultTopPosters)
+	if len(r.Args) > 0 {
+		var err error
+		if n, err = strconv.ParseUint(r.Args[0], 10, 64); err != nil || n == 0 || n > maxTopPosters {
+			return "", errCommandUsage
+		}
+	}
+
+	var stats []*model.PosterStats
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		stats, err = model.FindTopPosters(d.ctx, tx, n)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	if len(stats) == 0 {
+		return "Nobody has posted anything yet.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Top posters:")
+	for i, s := range stats {
+		fmt.Fprintf(&b, "\n%d. <@%d> — %d posts", i+1, s.User.DiscordID, s.Posts)
+	}
+	return b.String(), nil
+}
+
+func (d *Discord) commandPosts(r *commandRequest) (string, error) {
+	userID := r.UserID
+	if len(r.Args) > 0 {
+		userID = parseUserMention(r.Args[0])
+	}
+
+	u, err := model.ParseSnowflake(userID)
+	if err != nil {
+		return "", errCommandUsage
+	}
+
+	var count uint64
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		count, err = model.CountUserPosts(d.ctx, tx, model.NewUser(0, u))
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("<@%d> has made %d posts.", u, count), nil
+}
+
+func (d *Discord) commandBest(r *commandRequest) (string, error) {
+	pm := &model.Post{}
+	var count uint32
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		count, err = model.FindMostReactedPost(d.ctx, tx, model.WrapChannelID(r.ChannelID), pm)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	if pm.ID == 0 {
+		return "No posts in this channel have reactions yet.", nil
+	}
+
+	return fmt.Sprintf("The most reacted post in this channel has %d reactions: https://discord.com/channels/%s/%s/%d", count, r.GuildID, r.ChannelID, pm.DiscordID), nil
+}
+
+// commandSync shows progress of channel synchronization, followed by event handlers that failed since
+// startup (see HandlerFailures.)
+func (d *Discord) commandSync(*commandRequest) (string, error) {
+	var b strings.Builder
+	progress := d.SyncProgress()
+	if len(progress) == 0 {
+		b.WriteString("No channels have been synchronized yet.")
+	} else {
+		b.WriteString("Channel synchronization:")
+	}
+	for _, p := range progress {
+		fmt.Fprintf(&b, "\n<#%d> — %s", p.ChannelID, p.State)
+		if p.State == SyncRunning {
+			fmt.Fprintf(&b, " %s", p.Direction)
+		}
+		if p.Messages > 0 {
+			fmt.Fprintf(&b, ", %d messages", p.Messages)
+		}
+		if p.State == SyncFailed {
+			fmt.Fprintf(&b, ": %s", p.Error)
+		}
+	}
+
+	if failures := d.HandlerFailures(); len(failures) > 0 {
+		b.WriteString("\nEvent handler failures since startup:")
+		for _, f := range failures {
+			fmt.Fprintf(&b, "\n%s — %d", f.Event, f.Failures)
+		}
+	}
+	return b.String(), nil
+}
+
+func (d *Discord) commandTrack(r *commandRequest) (string, error) {
+	ID, reply, err := d.adminChannelArg(r)
+	if ID == "" {
+		return reply, err
+	}
+
+	if err := d.TrackChannel(ID); err != nil {
+		if errors.Is(err, errNotTextChannel) {
+			return fmt.Sprintf("<#%s> is not a text channel.", ID), nil
+		}
+		return "", err
+	}
+	return fmt.Sprintf("Tracking <#%s>, its history is being synchronized.", ID), nil
+}
+
+func (d *Discord) commandUntrack(r *commandRequest) (string, error) {
+	ID, reply, err := d.adminChannelArg(r)
+	if ID == "" {
+		return reply, err
+	}
+
+	if err := d.UntrackChannel(ID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Stopped tracking <#%s>, posts already stored are kept.", ID), nil
+}
+
+// adminChannelArg checks that the user issuing a tracking command can manage the guild, and returns ID of
+// the channel of this guild passed as its argument, or the channel command was sent in. If the command
+// cannot proceed, returned ID is empty and reply or error are set instead.
+func (d *Discord) adminChannelArg(r *commandRequest) (ID string, reply string, err error) {
+	perms, err := d.session.UserChannelPermissions(r.UserID, r.ChannelID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to retrieve permissions: %w", err)
+	}
+	if perms&discordgo.PermissionManageServer == 0 {
+		return "", "You need Manage Server permission to do that.", nil
+	}
+
+	ID = r.ChannelID
+	if len(r.Args) > 0 {
+		ID = parseChannelMention(r.Args[0])
+	}
+	if _, err := model.ParseSnowflake(ID); err != nil {
+		return "", "", errCommandUsage
+	}
+
+	if g, err := d.resolveChannelGuild(model.MustParseSnowflake(ID)); err != nil || strconv.FormatUint(g, 10) != r.GuildID {
+		return "", "Only channels of this server can be tracked from here.", nil
+	}
+	return ID, "", nil
+}
+
+// parseChannelMention extracts channel ID from a mention (<#ID>), returning s as is if it is not one.
+func parseChannelMention(s string) string {
+	if strings.HasPrefix(s, "<#") && strings.HasSuffix(s, ">") {
+		return s[2 : len(s)-1]
+	}
+	return s
+}
+
+// parseUserMention extracts user ID from a mention (<@ID> or <@!ID>), returning s as is if it is not one.
+func parseUserMention(s string) string {
+	if strings.HasPrefix(s, "<@") && strings.HasSuffix(s, ">") {
+		return strings.TrimPrefix(s[2:len(s)-1], "!")
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package discord
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sendingSession records messages sent by the bot. Other calls are not implemented.
+type sendingSession struct {
+	Session
+	sent []*discordgo.MessageSend
+}
+
+func (s *sendingSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	s.sent = append(s.sent, data)
+	return &discordgo.Message{ID: "100", ChannelID: channelID, Content: data.Content}, nil
+}
+
+func TestCommandReplyMentionsNobody(t *testing.T) {
+	s := &sendingSession{}
+	d := newTestDiscord(t, s, nil)
+	d.handlePrefixCommand(&discordgo.Message{ID: "5", ChannelID: "20", GuildID: "10", Content: "!monicu help", Author: &discordgo.User{ID: "1"}})
+
+	if len(s.sent) != 1 {
+		t.Fatalf("expected a single reply, got %d", len(s.sent))
+	}
+	reply := s.sent[0]
+	if !strings.HasPrefix(reply.Content, "Available commands:") {
+		t.Errorf("expected help, got %q", reply.Content)
+	}
+	if reply.Reference == nil || reply.Reference.MessageID != "5" {
+		t.Errorf("expected reply to message 5, got reference %+v", reply.Reference)
+	}
+
+	// Discord parses all mentions unless allowed mentions are sent with an empty list
+	data, err := json.Marshal(reply)
+	if err != nil {
+		t.Fatalf("failed to encode reply: %s", err)
+	}
+	if !strings.Contains(string(data), `"allowed_mentions":{"parse":[]}`) {
+		t.Errorf("expected no mentions to be allowed, got %s", data)
+	}
+}
+
+func TestSyncCommandShowsHandlerFailures(t *testing.T) {
+	d := newTestDiscord(t, nil, nil)
+	if reply, _ := d.commandSync(nil); strings.Contains(reply, "failures") {
+		t.Errorf("expected no failures, got %q", reply)
+	}
+
+	for _, e := range []string{"MessageDelete", "MessageCreate", "MessageDelete"} {
+		d.countHandlerFailure(e)
+	}
+	reply, err := d.commandSync(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "Event handler failures since startup:\nMessageCreate — 1\nMessageDelete — 2"; !strings.HasSuffix(reply, want) {
+		t.Errorf("expected reply to end with %q, got %q", want, reply)
+	}
+}
@@ -0,0 +1,174 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+const (
+	// failedEventsInterval is interval between checks for failed events due to be retried.
+	failedEventsInterval = time.Minute
+	// failedEventMinDelay and failedEventMaxDelay bound the exponential backoff between retries of a failed
+	// event.
+	failedEventMinDelay = time.Minute
+	failedEventMaxDelay = 24 * time.Hour
+)
+
+// failedEventDelay returns delay before the next retry of an event that failed the specified number of
+// times.
+func failedEventDelay(attempts int32) time.Duration {
+	delay := failedEventMinDelay
+	for i := int32(1); i < attempts && delay < failedEventMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > failedEventMaxDelay {
+		delay = failedEventMaxDelay
+	}
+	return delay
+}
+
+// storeFailedEvent stores event that could not be applied after the specified number of attempts, so
+// that it is retried later.
+func (d *Discord) storeFailedEvent(typ string, e interface{}, attempts int, cause error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		d.logger.Errorf("Failed to encode failed %s event: %s.", typ, err)
+		return
+	}
+
+	fe := &model.FailedEvent{
+		Type:          typ,
+		Payload:       payload,
+		Error:         cause.Error(),
+		Attempts:      int32(attempts),
+		NextAttemptAt: time.Now().Add(failedEventDelay(int32(attempts))),
+	}
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		return model.CreateFailedEvent(d.ctx, tx, fe)
+	}); err != nil {
+		d.logger.Errorf("Failed to store failed %s event: %s.", typ, err)
+		return
+	}
+	d.logger.Warnf("Stored failed %s event %d to be retried at %s.", typ, fe.ID, fe.NextAttemptAt.Format(time.RFC3339))
+}
+
+// runFailedEventRetries starts periodically retrying failed events that are due.
+func (d *Discord) runFailedEventRetries() {
+	d.workersWg.Add(1)
+	go func() {
+		defer d.workersWg.Done()
+		for {
+			d.retryFailedEvents()
+			if sleep(d.ctx, failedEventsInterval) != nil {
+				return
+			}
+		}
+	}()
+}
+
+// errStaleEvent is returned when retrying a failed event would overwrite a newer change of the message.
+var errStaleEvent = errors.New("newer edit of the message is already stored")
+
+// retryFailedEvents retries all failed events that are due, deleting the ones that succeed and
+// rescheduling the rest. Events are applied through the same dispatcher as queued events, so that they
+// are never applied along with other events of the same message.
+func (d *Discord) retryFailedEvents() {
+	var events []*model.FailedEvent
+	if err := d.storage.BeginReadOnly(d.ctx, func(tx pgx.Tx) (err error) {
+		events, err = model.FindDueFailedEvents(d.ctx, tx)
+		return
+	}); err != nil {
+		if d.ctx.Err() == nil {
+			d.logger.Errorf("Failed to find failed events: %s.", err)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, fe := range events {
+		fe := fe
+		e, err := decodeEvent(fe.Type, fe.Payload)
+		if err != nil {
+			d.completeFailedEvent(fe, fmt.Errorf("failed to decode event: %w", err))
+			continue
+		}
+
+		wg.Add(1)
+		if !d.events.dispatch(d.ctx, eventKeys(e), func() {
+			defer wg.Done()
+			d.completeFailedEvent(fe, d.retryFailedEvent(fe, e))
+		}) {
+			wg.Done()
+			return
+		}
+	}
+}
+
+// retryFailedEvent applies failed event e, unless it is an edit older than the stored one.
+func (d *Discord) retryFailedEvent(fe *model.FailedEvent, e interface{}) error {
+	if u, ok := e.(*discordgo.MessageUpdate); ok {
+		sm := model.WrapMessageID(u.ID)
+		if err := d.storage.BeginReadOnly(d.ctx, func(tx pgx.Tx) error {
+			return model.FindPost(d.ctx, tx, sm)
+		}); err != nil {
+			return fmt.Errorf("failed to find post: %w", err)
+		}
+		if isStaleUpdate(sm, u) {
+			return errStaleEvent
+		}
+	}
+	return d.safeApplyEvent(fe.Type, e)
+}
+
+// isStaleUpdate checks if stored post p was edited after the edit of message update e. An update without
+// edit timestamp, which is sent when e.g. embeds of a message are resolved, is older than any edit.
+func isStaleUpdate(p *model.Post, e *discordgo.MessageUpdate) bool {
+	if p.ID == 0 || !p.EditedAt.Valid {
+		return false
+	}
+	edited, err := e.EditedTimestamp.Parse()
+	return err != nil || p.EditedAt.Time.After(edited)
+}
+
+// completeFailedEvent deletes failed event once it succeeded or there is nothing left to apply it to, and
+// reschedules it otherwise, err being the outcome of retrying it.
+func (d *Discord) completeFailedEvent(fe *model.FailedEvent, err error) {
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	if err == nil || errors.Is(err, errPostNotStored) || errors.Is(err, errStaleEvent) {
+		if err != nil {
+			// Referenced post was deleted or changed since
+			d.logger.Warnf("Discarding failed %s event %d: %s.", fe.Type, fe.ID, err)
+		} else {
+			d.logger.Infof("Applied failed %s event %d after %d attempts.", fe.Type, fe.ID, fe.Attempts+1)
+		}
+		if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+			_, err := model.DeleteFailedEvent(d.ctx, tx, fe.ID)
+			return err
+		}); err != nil {
+			d.logger.Errorf("Failed to delete failed event %d: %s.", fe.ID, err)
+		}
+		return
+	}
+
+	fe.Attempts++
+	fe.Error = err.Error()
+	fe.NextAttemptAt = time.Now().Add(failedEventDelay(fe.Attempts))
+	d.logger.Warnf("Failed to retry %s event %d (attempt %d), retrying at %s: %s.", fe.Type, fe.ID, fe.Attempts, fe.NextAttemptAt.Format(time.RFC3339), err)
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		_, err := model.UpdateFailedEvent(d.ctx, tx, fe)
+		return err
+	}); err != nil {
+		d.logger.Errorf("Failed to update failed event %d: %s.", fe.ID, err)
+	}
+}
@@ -0,0 +1,44 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+func TestIsStaleUpdate(t *testing.T) {
+	edited := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	stored := func(ID model.ID, editedAt time.Time) *model.Post {
+		p := model.NewPost(ID, 1, 1, 1, "post")
+		p.EditedAt = model.NullableTime{Time: editedAt, Valid: !editedAt.IsZero()}
+		return p
+	}
+	update := func(editedAt time.Time) *discordgo.MessageUpdate {
+		m := &discordgo.Message{ID: "1"}
+		if !editedAt.IsZero() {
+			m.EditedTimestamp = discordgo.Timestamp(editedAt.Format(time.RFC3339Nano))
+		}
+		return &discordgo.MessageUpdate{Message: m}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		post  *model.Post
+		event *discordgo.MessageUpdate
+		stale bool
+	}{
+		{"not stored", stored(0, edited), update(edited.Add(-time.Minute)), false},
+		{"stored not edited", stored(1, time.Time{}), update(edited), false},
+		{"older edit", stored(1, edited), update(edited.Add(-time.Minute)), true},
+		{"same edit", stored(1, edited), update(edited), false},
+		{"newer edit", stored(1, edited), update(edited.Add(time.Minute)), false},
+		{"update without edit", stored(1, edited), update(time.Time{}), true},
+		{"update without edit of unedited post", stored(1, time.Time{}), update(time.Time{}), false},
+	} {
+		if got := isStaleUpdate(tt.post, tt.event); got != tt.stale {
+			t.Errorf("%s: expected stale %t, got %t", tt.name, tt.stale, got)
+		}
+	}
+}
@@ -2,39 +2,101 @@ package discord
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/journal"
 	"pkg.mon.icu/monicu/internal/storage"
 	"pkg.mon.icu/monicu/internal/storage/model"
 )
 
 type Config struct {
-	guilds       *Uint64Set
-	chans        *Uint64Set
-	ignoreRegexp *regexp.Regexp
+	guilds          *Uint64Set
+	chans           *Uint64Set
+	ignoreRegexp    *regexp.Regexp
+	commandPrefix   string
+	reconcileWindow time.Duration
+	syncWorkers     int
+	restConcurrency int
+
+	autoTrackCategories *Uint64Set
+	autoTrackRegexp     *regexp.Regexp
+	deletePolicy        DeletePolicy
+	memberEvents        bool
+
+	queueDir         string
+	queueMaxAttempts int
+	queueWorkers     int
 }
 
-func NewConfig(guilds, channels []uint64, ignoreRegexp *regexp.Regexp) *Config {
+func NewConfig(guilds, channels []uint64, ignoreRegexp *regexp.Regexp, commandPrefix string, reconcileWindow time.Duration, syncWorkers int, restConcurrency int, autoTrackCategories []uint64, autoTrackRegexp *regexp.Regexp, deletePolicy DeletePolicy, memberEvents bool, queueDir string, queueMaxAttempts int, queueWorkers int) *Config {
+	if syncWorkers < 1 {
+		syncWorkers = 1
+	}
+	if queueMaxAttempts < 1 {
+		queueMaxAttempts = 1
+	}
 	return &Config{
-		guilds:       NewUint64Set(guilds),
-		chans:        NewUint64Set(channels),
-		ignoreRegexp: ignoreRegexp,
+		guilds:              NewUint64Set(guilds),
+		chans:               NewUint64Set(channels),
+		ignoreRegexp:        ignoreRegexp,
+		commandPrefix:       commandPrefix,
+		reconcileWindow:     reconcileWindow,
+		syncWorkers:         syncWorkers,
+		restConcurrency:     restConcurrency,
+		autoTrackCategories: NewUint64Set(autoTrackCategories),
+		autoTrackRegexp:     autoTrackRegexp,
+		deletePolicy:        deletePolicy,
+		memberEvents:        memberEvents,
+		queueDir:            queueDir,
+		queueMaxAttempts:    queueMaxAttempts,
+		queueWorkers:        queueWorkers,
 	}
 }
 
 type Discord struct {
 	ctx    context.Context
+	cancel context.CancelFunc
 	logger *zap.SugaredLogger
 
-	session       *discordgo.Session
+	session       Session
 	handlerRemFns []func()
 
-	config                *Config
-	storage               *storage.Storage
+	config   *Config
+	storage  *storage.Storage
+	initOnce sync.Once
+
+	// guilds and chans are guilds and channels being tracked, seeded from config and then changed at
+	// runtime (see tracking.go.)
+	guilds                *Uint64Set
+	chans                 *Uint64Set
+	relationsMu           sync.RWMutex
 	channelGuildRelations map[uint64]uint64
+	namesMu               sync.RWMutex
+	names                 map[uint64]string
+
+	gate      *restGate
+	syncQueue chan uint64
+	workersWg sync.WaitGroup
+	syncingMu sync.Mutex
+	syncing   map[uint64]struct{}
+
+	// queue holds message and reaction events until they are stored (see queue.go.) Both queued and
+	// failed events are applied through events, so that events of a message are never applied at once.
+	queue  *journal.Journal
+	events *dispatcher
+
+	progressMu sync.Mutex
+	progress   map[uint64]*SyncProgress
+
+	failuresMu sync.Mutex
+	failures   map[string]uint64
 }
 
 func NewDiscord(ctx context.Context, log *zap.SugaredLogger, auth string, config *Config, store *storage.Storage) (*Discord, error) {
@@ -43,58 +105,172 @@ func NewDiscord(ctx context.Context, log *zap.SugaredLogger, auth string, config
 		return nil, err
 	}
 
-	d := &Discord{
+	// Handlers run one at a time in order events are received, instead of each in a new goroutine, so that
+	// events of a message are queued in order (see addHandlers)
+	s.SyncEvents = true
+	if config.memberEvents {
+		// Privileged intent, has to be enabled for the bot in Discord developer portal
+		s.Identify.Intents |= discordgo.IntentsGuildMembers
+	}
+
+	return NewDiscordSession(ctx, log, s, config, store), nil
+}
+
+// NewDiscordSession creates Discord using the specified session, such as fake.Discord to run the bot
+// against Discord kept in memory.
+func NewDiscordSession(ctx context.Context, log *zap.SugaredLogger, s Session, config *Config, store *storage.Storage) *Discord {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Discord{
 		ctx:                   ctx,
+		cancel:                cancel,
 		logger:                log,
 		session:               s,
 		handlerRemFns:         make([]func(), 0, 8),
 		config:                config,
 		storage:               store,
+		guilds:                NewUint64Set(nil),
+		chans:                 NewUint64Set(nil),
 		channelGuildRelations: make(map[uint64]uint64),
+		names:                 make(map[uint64]string),
+		gate:                  newRestGate(config.restConcurrency),
+		syncQueue:             make(chan uint64),
+		syncing:               make(map[uint64]struct{}),
+		events:                newDispatcher(config.queueWorkers),
+		progress:              make(map[uint64]*SyncProgress),
+		failures:              make(map[string]uint64),
 	}
-
-	return d, nil
 }
 
 func (d *Discord) addHandlers() {
-	d.handlerRemFns = append(d.handlerRemFns, d.session.AddHandlerOnce(d.onReady))
+	// Message and reaction handlers only append events to the queue, and run on the gateway goroutine so
+	// that events are queued in order they were received
 	for _, h := range []interface{}{
-		d.onMessageUpdate,
 		d.onMessageCreate,
+		d.onMessageUpdate,
 		d.onMessageDelete,
 		d.onMessageDeleteBulk,
 		d.onMessageReactionAdd,
 		d.onMessageReactionRemove,
 		d.onMessageReactionRemoveAll,
 	} {
-		d.handlerRemFns = append(d.handlerRemFns, d.session.AddHandler(h))
+		d.handlerRemFns = append(d.handlerRemFns, d.session.AddHandler(d.recovering(h)))
+	}
+
+	// Other handlers make REST calls and write to database, so they run in their own goroutines not to hold
+	// up the gateway
+	for _, h := range []interface{}{
+		d.onReady,
+		d.onResumed,
+		d.onRateLimit,
+		d.onGuildCreate,
+		d.onGuildUpdate,
+		d.onGuildDelete,
+		d.onChannelCreate,
+		d.onChannelUpdate,
+		d.onChannelDelete,
+		d.onGuildMemberUpdate,
+		d.onCommand,
+	} {
+		d.handlerRemFns = append(d.handlerRemFns, d.session.AddHandler(concurrent(d.recovering(h))))
 	}
 }
 
+// concurrent wraps event handler h, so that it runs in a new goroutine. The returned handler has the same
+// type as h.
+func concurrent(h interface{}) interface{} {
+	v := reflect.ValueOf(h)
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		go v.Call(args)
+		return nil
+	}).Interface()
+}
+
 func (d *Discord) removeHandlers() {
 	for _, removeHandler := range d.handlerRemFns {
 		removeHandler()
 	}
 }
 
+// buildChannelGuildCache resolves guilds of all tracked channels.
 func (d *Discord) buildChannelGuildCache() {
-	for _, chanID := range d.config.chans.Values() {
-		chann, err := d.session.Channel(strconv.FormatUint(chanID, 10))
-		if err != nil {
-			d.logger.Errorf("Failed to retrieve channel %d: %s.", chanID, err)
-			continue
+	for _, chanID := range d.chans.Values() {
+		if _, err := d.resolveChannelGuild(chanID); err != nil {
+			d.logger.Errorf("Failed to retrieve channel %s: %s.", d.describe(chanID), err)
 		}
+	}
+}
+
+// resolveChannelGuild returns ID of guild of channel with the specified ID, retrieving the channel from
+// Discord if it is not cached yet.
+func (d *Discord) resolveChannelGuild(chanID uint64) (uint64, error) {
+	d.relationsMu.RLock()
+	g, ok := d.channelGuildRelations[chanID]
+	d.relationsMu.RUnlock()
+	if ok {
+		return g, nil
+	}
 
-		d.channelGuildRelations[chanID] = model.MustParseSnowflake(chann.GuildID)
+	var chann *discordgo.Channel
+	if err := d.rest(priorityLive, func() (err error) {
+		chann, err = d.session.Channel(strconv.FormatUint(chanID, 10))
+		return
+	}); err != nil {
+		return 0, err
+	}
+	if chann.GuildID == "" {
+		return 0, fmt.Errorf("channel %d is not a guild channel", chanID)
+	}
+
+	g, err := model.ParseSnowflake(chann.GuildID)
+	if err != nil {
+		return 0, err
 	}
+	d.setChannelGuild(chanID, g)
+	d.setName(chanID, "#"+chann.Name)
+	return g, nil
+}
+
+func (d *Discord) setChannelGuild(chanID, guildID uint64) {
+	d.relationsMu.Lock()
+	defer d.relationsMu.Unlock()
+	d.channelGuildRelations[chanID] = guildID
+}
+
+// channelGuild returns ID of guild of channel with the specified ID from the cache.
+func (d *Discord) channelGuild(ID string) string {
+	d.relationsMu.RLock()
+	defer d.relationsMu.RUnlock()
+	return strconv.FormatUint(d.channelGuildRelations[model.MustParseSnowflake(ID)], 10)
 }
 
 func (d *Discord) Connect() error {
+	if err := d.loadTracking(); err != nil {
+		return fmt.Errorf("failed to load tracked channels: %w", err)
+	}
+	q, err := journal.Open(d.config.queueDir)
+	if err != nil {
+		return fmt.Errorf("failed to open event queue: %w", err)
+	}
+	d.queue = q
+	d.runQueueConsumer()
+	d.runFailedEventRetries()
+	d.runSyncWorkers()
+	d.listenTracking()
 	d.addHandlers()
 	return d.session.Open()
 }
 
+// Close disconnects from Discord and waits for sync workers and the queue consumer to stop. Channels
+// interrupted mid-sync are resumed from their checkpoints on the next start, as are queued events.
 func (d *Discord) Close() error {
 	d.removeHandlers()
-	return d.session.Close()
+	err := d.session.Close()
+	d.cancel()
+	d.workersWg.Wait()
+	if d.queue != nil {
+		if qerr := d.queue.Close(); err == nil {
+			err = qerr
+		}
+	}
+	return err
 }
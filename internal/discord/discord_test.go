@@ -0,0 +1,40 @@
+package discord
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/journal"
+	"pkg.mon.icu/monicu/internal/storage"
+)
+
+// newTestConfig returns config with the queue in a temporary directory.
+func newTestConfig(t *testing.T) *Config {
+	return NewConfig(nil, nil, regexp.MustCompile("nopost"), "!monicu ", 72*time.Hour, 1, 4, nil, nil, DeleteArchive, false, t.TempDir(), 3, 4)
+}
+
+// newTestDiscord creates Discord using the specified session and storage, which may be nil.
+func newTestDiscord(t *testing.T, s Session, store *storage.Storage) *Discord {
+	return NewDiscordSession(context.Background(), zap.NewNop().Sugar(), s, newTestConfig(t), store)
+}
+
+// prepareTestDiscord makes d track the specified guilds and channels and opens its queue, without
+// connecting.
+func prepareTestDiscord(t *testing.T, d *Discord, guilds, chans []uint64) {
+	d.guilds.Replace(guilds)
+	d.chans.Replace(chans)
+
+	q, err := journal.Open(d.config.queueDir)
+	if err != nil {
+		t.Fatalf("failed to open queue: %s", err)
+	}
+	d.queue = q
+	t.Cleanup(func() {
+		d.cancel()
+		d.workersWg.Wait()
+		_ = q.Close()
+	})
+}
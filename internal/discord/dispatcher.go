@@ -0,0 +1,116 @@
+package discord
+
+import (
+	"context"
+	"sync"
+)
+
+// dispatcher runs tasks in parallel up to a limit, except for tasks sharing a key, which run one at a time
+// in order they were dispatched. Every key has its own queue, so a slow task delays only later tasks of
+// its keys.
+type dispatcher struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	// queues holds tasks of every key in order they were dispatched, starting with the one running
+	queues map[string][]*dispatcherTask
+	// ready holds tasks first in queues of all their keys, waiting for room to run
+	ready []*dispatcherTask
+	wg    sync.WaitGroup
+}
+
+type dispatcherTask struct {
+	keys []string
+	fn   func()
+	// blocked is the number of keys whose queue the task is not first in
+	blocked int
+}
+
+func newDispatcher(limit int) *dispatcher {
+	if limit < 1 {
+		limit = 1
+	}
+	return &dispatcher{limit: limit, queues: make(map[string][]*dispatcherTask)}
+}
+
+// dispatch queues fn to be run in a new goroutine once tasks dispatched earlier with any of the keys
+// finish and there is room for another task. Returns false without queuing fn if ctx is done. Tasks
+// queued before ctx is done are still run.
+func (ds *dispatcher) dispatch(ctx context.Context, keys []string, fn func()) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	t := &dispatcherTask{keys: dedupKeys(keys), fn: fn}
+	ds.wg.Add(1)
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	for _, k := range t.keys {
+		if len(ds.queues[k]) > 0 {
+			t.blocked++
+		}
+		ds.queues[k] = append(ds.queues[k], t)
+	}
+	if t.blocked == 0 {
+		ds.ready = append(ds.ready, t)
+	}
+	ds.schedule()
+	return true
+}
+
+// schedule starts ready tasks while there is room for them. It must be called with mu held.
+func (ds *dispatcher) schedule() {
+	for ds.running < ds.limit && len(ds.ready) > 0 {
+		t := ds.ready[0]
+		ds.ready[0], ds.ready = nil, ds.ready[1:]
+		ds.running++
+		go ds.run(t)
+	}
+}
+
+func (ds *dispatcher) run(t *dispatcherTask) {
+	defer ds.wg.Done()
+	defer ds.release(t)
+	t.fn()
+}
+
+// release removes finished task from queues of its keys, making the tasks following it ready if they are
+// not blocked by other keys.
+func (ds *dispatcher) release(t *dispatcherTask) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.running--
+	for _, k := range t.keys {
+		q := ds.queues[k][1:]
+		if len(q) == 0 {
+			delete(ds.queues, k)
+			continue
+		}
+
+		ds.queues[k] = q
+		if q[0].blocked--; q[0].blocked == 0 {
+			ds.ready = append(ds.ready, q[0])
+		}
+	}
+	ds.schedule()
+}
+
+// wait waits for all dispatched tasks to finish.
+func (ds *dispatcher) wait() {
+	ds.wg.Wait()
+}
+
+// dedupKeys returns keys without duplicates, which would otherwise make a task wait for itself.
+func dedupKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	unique := keys[:0:0]
+	for _, k := range keys {
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
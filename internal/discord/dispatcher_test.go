@@ -0,0 +1,90 @@
+package discord
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherKeepsOrderOfKey(t *testing.T) {
+	ds := newDispatcher(4)
+
+	var mu sync.Mutex
+	var order []int
+	running := 0
+	for i := 0; i < 50; i++ {
+		i := i
+		keys := []string{"a"}
+		if i%10 == 0 {
+			keys = append(keys, "b", "a")
+		}
+		if !ds.dispatch(context.Background(), keys, func() {
+			mu.Lock()
+			running++
+			if running > 1 {
+				t.Errorf("task %d runs along with another task of the key", i)
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			order = append(order, i)
+			mu.Unlock()
+		}) {
+			t.Fatal("expected task to be dispatched")
+		}
+	}
+	ds.wait()
+
+	if len(order) != 50 {
+		t.Fatalf("expected 50 tasks to run, got %d", len(order))
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("expected task %d at %d, got %d", i, i, got)
+		}
+	}
+}
+
+func TestDispatcherRunsKeysInParallel(t *testing.T) {
+	ds := newDispatcher(2)
+	defer ds.wait()
+
+	// Tasks of key a are blocked until the task of key b runs, which would never happen if it waited for
+	// them
+	unblock := make(chan struct{})
+	ran := make(chan string, 3)
+	for i := 0; i < 2; i++ {
+		ds.dispatch(context.Background(), []string{"a"}, func() {
+			<-unblock
+			ran <- "a"
+		})
+	}
+	ds.dispatch(context.Background(), []string{"b"}, func() {
+		ran <- "b"
+	})
+
+	select {
+	case k := <-ran:
+		if k != "b" {
+			t.Errorf("expected task of key b to run first, got %s", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected task of key b to run while tasks of key a are blocked")
+	}
+	close(unblock)
+}
+
+func TestDispatcherStopped(t *testing.T) {
+	ds := newDispatcher(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if ds.dispatch(ctx, []string{"a"}, func() { t.Error("expected task not to run") }) {
+		t.Error("expected task not to be dispatched after context is done")
+	}
+	ds.wait()
+}
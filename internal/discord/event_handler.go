@@ -2,7 +2,6 @@ package discord
 
 import (
 	"fmt"
-	"reflect"
 
 	"github.com/bwmarrin/discordgo"
 	"pkg.mon.icu/monicu/internal/storage/model"
@@ -10,126 +9,151 @@ import (
 
 // Util functions
 
-// isGuildEvent checks if the specified event (see list of handled events below)
-// is originating from a guild (aka server.)
+// eventIDs returns guild and channel ID of the specified event (see list of handled events below.) Guild
+// ID is empty for events not originating from a guild (aka server), as well as for most MessageUpdate
+// events.
 //
 // Handled event types: MessageCreate, MessageUpdate, MessageDelete, MessageDeleteBulk, MessageReactionAdd,
 // MessageReactionRemove, MessageReactionRemoveAll.
-func isGuildEvent(e interface{}) bool {
+func eventIDs(e interface{}) (guildID, channelID string, err error) {
 	switch e := e.(type) {
-	case *discordgo.MessageUpdate:
-		return e.GuildID != ""
 	case *discordgo.MessageCreate:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
+	case *discordgo.MessageUpdate:
+		return e.GuildID, e.ChannelID, nil
 	case *discordgo.MessageDelete:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
 	case *discordgo.MessageDeleteBulk:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
 	case *discordgo.MessageReactionAdd:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
 	case *discordgo.MessageReactionRemove:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
 	case *discordgo.MessageReactionRemoveAll:
-		return e.GuildID != ""
+		return e.GuildID, e.ChannelID, nil
 	default:
-		panic(fmt.Errorf("unknown event type %s", reflect.TypeOf(e).Name()))
+		return "", "", fmt.Errorf("unknown event type %T", e)
 	}
 }
 
-// shouldIgnoreEvent checks if the specified event (see list of handled events below)
-// should be ignored and not handled furthermore.
+// shouldIgnoreEvent checks if the specified event (see eventIDs) should be ignored and not handled
+// furthermore.
 //
-// First, function checks if event is a guild event (see isGuildEvent, except for MessageUpdate, which
-// most probably will have GuildID field omitted.)
+// First, function checks if event is a guild event (except for MessageUpdate, which most probably will
+// have GuildID field omitted, in which case only its channel is checked.)
 //
-// Then, it checks if event's guild is in config as well as its channel.
-//
-// Handled event types: MessageCreate, MessageUpdate, MessageDelete, MessageDeleteBulk, MessageReactionAdd,
-// MessageReactionRemove, MessageReactionRemoveAll.
+// Then, it checks if event's guild is tracked as well as its channel. Events of unknown types or with
+// malformed IDs are logged and ignored.
 func (d *Discord) shouldIgnoreEvent(e interface{}) bool {
-	var gID, cID model.Snowflake
-	switch e := e.(type) {
-	case *discordgo.MessageCreate:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageUpdate:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageDelete:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageDeleteBulk:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageReactionAdd:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageReactionRemove:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	case *discordgo.MessageReactionRemoveAll:
-		gID, cID = model.MustParseSnowflake(e.GuildID), model.MustParseSnowflake(e.ChannelID)
-	default:
-		panic(fmt.Errorf("unknown event type %s", reflect.TypeOf(e).Name()))
+	gID, cID, err := eventIDs(e)
+	if err != nil {
+		d.logger.Errorf("Failed to handle event: %s.", err)
+		return true
 	}
 
-	_, update := e.(*discordgo.MessageUpdate)
-	if isGuildEvent(e) || update {
-		if !d.config.guilds.Contains(gID) {
+	c, err := model.ParseSnowflake(cID)
+	if err != nil {
+		d.logger.Warnf("Ignoring %T with malformed channel ID %q.", e, cID)
+		return true
+	}
+
+	if gID == "" {
+		if _, update := e.(*discordgo.MessageUpdate); !update {
 			return true
 		}
+		// Tracked channels always belong to tracked guilds
+		return !d.chans.Contains(c)
+	}
+
+	g, err := model.ParseSnowflake(gID)
+	if err != nil {
+		d.logger.Warnf("Ignoring %T with malformed guild ID %q.", e, gID)
+		return true
 	}
 
-	return !d.config.chans.Contains(cID)
+	return !d.guilds.Contains(g) || !d.chans.Contains(c)
+}
+
+// trackedGuild parses guild ID of an event, checking if the guild is tracked. Events not originating
+// from a guild have empty guild ID, which is never tracked.
+func (d *Discord) trackedGuild(ID string) (uint64, bool) {
+	g, err := model.ParseSnowflake(ID)
+	return g, err == nil && d.guilds.Contains(g)
 }
 
 // Event handlers
 
+// onReady is called both on the initial connection and on every reconnection that could not resume the
+// previous gateway session, in which case events sent in between are lost and channels have to catch up.
 func (d *Discord) onReady(_ *discordgo.Session, _ *discordgo.Ready) {
-	d.buildChannelGuildCache()
-	d.createChannelsAndGuilds()
+	d.initOnce.Do(func() {
+		d.buildChannelGuildCache()
+		d.createChannelsAndGuilds()
+	})
+	d.syncChannels()
+}
+
+// onResumed is called when a gateway session is resumed after a disconnection. Discord replays missed
+// events on resume, but channels still catch up in case the outage was long enough to drop some.
+func (d *Discord) onResumed(_ *discordgo.Session, _ *discordgo.Resumed) {
 	d.syncChannels()
 }
 
 func (d *Discord) onMessageCreate(_ *discordgo.Session, e *discordgo.MessageCreate) {
-	if d.shouldIgnoreEvent(e) {
+	if d.isCommand(e.Message) || d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.createPost(e.Message)
+	d.enqueue(e)
+}
+
+// onCommand serves commands, which are served in every channel of tracked guilds.
+func (d *Discord) onCommand(_ *discordgo.Session, e *discordgo.MessageCreate) {
+	if !d.isCommand(e.Message) {
+		return
+	}
+	if _, tracked := d.trackedGuild(e.GuildID); tracked {
+		d.handlePrefixCommand(e.Message)
+	}
 }
 
 func (d *Discord) onMessageUpdate(_ *discordgo.Session, e *discordgo.MessageUpdate) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.updatePost(e.Message)
+	d.enqueue(e)
 }
 
 func (d *Discord) onMessageDelete(_ *discordgo.Session, e *discordgo.MessageDelete) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.deletePost(e.Message)
+	d.enqueue(e)
 }
 
 func (d *Discord) onMessageDeleteBulk(_ *discordgo.Session, e *discordgo.MessageDeleteBulk) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.deletePostsBulk(e.Messages)
+	d.enqueue(e)
 }
 
 func (d *Discord) onMessageReactionAdd(_ *discordgo.Session, e *discordgo.MessageReactionAdd) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.addReaction(e.MessageReaction)
+	d.enqueue(e)
 }
 
 func (d *Discord) onMessageReactionRemove(_ *discordgo.Session, e *discordgo.MessageReactionRemove) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.removeReaction(e.MessageReaction)
+	d.enqueue(e)
 }
 
 func (d *Discord) onMessageReactionRemoveAll(_ *discordgo.Session, e *discordgo.MessageReactionRemoveAll) {
 	if d.shouldIgnoreEvent(e) {
 		return
 	}
-	d.removeReactionsBulk(e.MessageReaction)
+	d.enqueue(e)
 }
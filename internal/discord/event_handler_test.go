@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// testEvents creates events of every handled type (see eventIDs) with the specified guild and channel.
+var testEvents = map[string]func(guildID, channelID string) interface{}{
+	"MessageCreate": func(g, c string) interface{} {
+		return &discordgo.MessageCreate{Message: &discordgo.Message{ID: "1", GuildID: g, ChannelID: c}}
+	},
+	"MessageUpdate": func(g, c string) interface{} {
+		return &discordgo.MessageUpdate{Message: &discordgo.Message{ID: "1", GuildID: g, ChannelID: c}}
+	},
+	"MessageDelete": func(g, c string) interface{} {
+		return &discordgo.MessageDelete{Message: &discordgo.Message{ID: "1", GuildID: g, ChannelID: c}}
+	},
+	"MessageDeleteBulk": func(g, c string) interface{} {
+		return &discordgo.MessageDeleteBulk{Messages: []string{"1"}, GuildID: g, ChannelID: c}
+	},
+	"MessageReactionAdd": func(g, c string) interface{} {
+		return &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{MessageID: "1", GuildID: g, ChannelID: c}}
+	},
+	"MessageReactionRemove": func(g, c string) interface{} {
+		return &discordgo.MessageReactionRemove{MessageReaction: &discordgo.MessageReaction{MessageID: "1", GuildID: g, ChannelID: c}}
+	},
+	"MessageReactionRemoveAll": func(g, c string) interface{} {
+		return &discordgo.MessageReactionRemoveAll{MessageReaction: &discordgo.MessageReaction{MessageID: "1", GuildID: g, ChannelID: c}}
+	},
+}
+
+func TestEventIDs(t *testing.T) {
+	for typ, newEvent := range testEvents {
+		for _, ids := range [][2]string{{"10", "20"}, {"", "20"}, {"", ""}, {"guild", "channel"}} {
+			g, c, err := eventIDs(newEvent(ids[0], ids[1]))
+			if err != nil {
+				t.Errorf("%s %v: unexpected error: %s", typ, ids, err)
+			} else if g != ids[0] || c != ids[1] {
+				t.Errorf("%s %v: expected IDs %q and %q, got %q and %q", typ, ids, ids[0], ids[1], g, c)
+			}
+		}
+	}
+
+	for _, e := range []interface{}{nil, &discordgo.Ready{}, discordgo.MessageCreate{}} {
+		if _, _, err := eventIDs(e); err == nil {
+			t.Errorf("%T: expected error for unknown event type", e)
+		}
+	}
+}
+
+func TestShouldIgnoreEvent(t *testing.T) {
+	d := newTestDiscord(t, nil, nil)
+	d.guilds.Replace([]uint64{10})
+	d.chans.Replace([]uint64{20})
+
+	tests := []struct {
+		name      string
+		guildID   string
+		channelID string
+		ignored   bool
+		// updateIgnored is the expected result for MessageUpdate, which is checked only by its channel if
+		// it has no guild ID.
+		updateIgnored bool
+	}{
+		{"tracked", "10", "20", false, false},
+		{"untracked guild", "11", "20", true, true},
+		{"untracked channel", "10", "21", true, true},
+		{"no guild", "", "20", true, false},
+		{"no guild and untracked channel", "", "21", true, true},
+		{"empty", "", "", true, true},
+		{"no channel", "10", "", true, true},
+		{"malformed guild", "guild", "20", true, true},
+		{"malformed channel", "10", "channel", true, true},
+		{"negative channel", "10", "-20", true, true},
+	}
+	for typ, newEvent := range testEvents {
+		for _, tt := range tests {
+			want := tt.ignored
+			if typ == "MessageUpdate" {
+				want = tt.updateIgnored
+			}
+			if got := d.shouldIgnoreEvent(newEvent(tt.guildID, tt.channelID)); got != want {
+				t.Errorf("%s %s: expected ignored %t, got %t", typ, tt.name, want, got)
+			}
+		}
+	}
+
+	for _, e := range []interface{}{nil, &discordgo.Ready{}, &discordgo.GuildCreate{}} {
+		if !d.shouldIgnoreEvent(e) {
+			t.Errorf("%T: expected event of unknown type to be ignored", e)
+		}
+	}
+}
+
+func TestMalformedGatewayIDsIgnored(t *testing.T) {
+	// Without storage, anything getting past the IDs would panic
+	d := newTestDiscord(t, nil, nil)
+	ch := &discordgo.Channel{ID: "channel", GuildID: "10", ParentID: "category", Name: "images", Type: discordgo.ChannelTypeGuildText}
+
+	if d.matchesAutoTrack(ch) {
+		t.Error("expected channel in malformed category not to match auto-track")
+	}
+	d.autoTrack(ch, 10)
+	d.storeChannel(ch)
+	d.storeGuild(&discordgo.Guild{ID: "guild", Name: "guild"})
+	if md := model.WrapDiscordChannel(ch); md.ParentID.Valid {
+		t.Errorf("expected malformed parent to be omitted, got %v", md.ParentID)
+	}
+}
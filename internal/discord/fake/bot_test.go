@@ -0,0 +1,205 @@
+package fake
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/discord"
+	"pkg.mon.icu/monicu/internal/storage"
+	"pkg.mon.icu/monicu/internal/storage/model"
+	"pkg.mon.icu/monicu/internal/storage/storagetest"
+)
+
+// testImage is an attachment making a message a post.
+var testImage = &discordgo.MessageAttachment{URL: "https://cdn.example.com/image.png", Filename: "image.png", Width: 64, Height: 48, Size: 1024}
+
+// testBot runs the bot against fake Discord, tracking a single channel.
+type testBot struct {
+	t        *testing.T
+	f        *Discord
+	s        *storage.Storage
+	queueDir string
+	guild    *discordgo.Guild
+	channel  *discordgo.Channel
+	user     *discordgo.User
+}
+
+func newTestBot(t *testing.T) *testBot {
+	s := storagetest.Open(t)
+	f := New()
+	g := f.AddGuild("guild")
+	ch, err := f.AddChannel(g.ID, "", "images")
+	if err != nil {
+		t.Fatalf("failed to add channel: %s", err)
+	}
+	return &testBot{t: t, f: f, s: s, queueDir: t.TempDir(), guild: g, channel: ch, user: f.AddUser("user")}
+}
+
+// start connects the bot and waits until the channel is synchronized. The bot is closed when the test
+// finishes, unless it is closed earlier.
+func (b *testBot) start() *discord.Discord {
+	config := discord.NewConfig([]uint64{snowflake(b.guild.ID)}, []uint64{snowflake(b.channel.ID)}, regexp.MustCompile("nopost"), "!monicu ", 72*time.Hour, 1, 4, nil, nil, discord.DeleteArchive, false, b.queueDir, 3, 4)
+	d := discord.NewDiscordSession(context.Background(), zap.NewNop().Sugar(), b.f, config, b.s)
+	if err := d.Connect(); err != nil {
+		b.t.Fatalf("failed to connect: %s", err)
+	}
+	b.t.Cleanup(func() { _ = d.Close() })
+
+	b.waitFor("channel to be synchronized", func() bool {
+		for _, p := range d.SyncProgress() {
+			if p.ChannelID == snowflake(b.channel.ID) && p.State == discord.SyncFinished {
+				return true
+			}
+		}
+		return false
+	})
+	return d
+}
+
+func (b *testBot) send(content string, attachments ...*discordgo.MessageAttachment) *discordgo.Message {
+	m, err := b.f.SendMessage(b.channel.ID, b.user, content, attachments...)
+	if err != nil {
+		b.t.Fatalf("failed to send message: %s", err)
+	}
+	return m
+}
+
+// waitFor waits until cond is true, failing the test if it is not in a few seconds.
+func (b *testBot) waitFor(what string, cond func() bool) {
+	b.t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			b.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// post returns post stored for message with the specified ID, which has zero ID if it is not stored.
+func (b *testBot) post(messageID string) *model.Post {
+	b.t.Helper()
+	p := model.WrapMessageID(messageID)
+	if err := b.s.BeginReadOnly(context.Background(), func(tx pgx.Tx) error {
+		return model.FindPost(context.Background(), tx, p)
+	}); err != nil {
+		b.t.Fatalf("failed to find post: %s", err)
+	}
+	return p
+}
+
+// reactions returns number of users that reacted to post stored for message with the specified ID.
+func (b *testBot) reactions(messageID string) uint32 {
+	b.t.Helper()
+	var count uint32
+	if err := b.s.BeginReadOnly(context.Background(), func(tx pgx.Tx) (err error) {
+		count, err = model.CountUserReactions(context.Background(), tx, b.post(messageID))
+		return
+	}); err != nil {
+		b.t.Fatalf("failed to count reactions: %s", err)
+	}
+	return count
+}
+
+func TestBotSynchronizesChannel(t *testing.T) {
+	b := newTestBot(t)
+	backfilled := []*discordgo.Message{b.send("first", testImage), b.send("second", testImage), b.send("third", testImage)}
+	text := b.send("no image")
+	if err := b.f.AddReaction(b.channel.ID, backfilled[1].ID, b.user, discordgo.Emoji{Name: "👍"}); err != nil {
+		t.Fatalf("failed to add reaction: %s", err)
+	}
+
+	d := b.start()
+	for _, m := range backfilled {
+		if p := b.post(m.ID); p.ID == 0 || p.Message != m.Content {
+			t.Errorf("expected backfilled message %s to be stored, got %+v", m.ID, p)
+		}
+	}
+	if p := b.post(text.ID); p.ID != 0 {
+		t.Errorf("expected message %s without image not to be stored", text.ID)
+	}
+	if n := b.reactions(backfilled[1].ID); n != 1 {
+		t.Errorf("expected backfilled reaction to be stored, got %d reactions", n)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("failed to close: %s", err)
+	}
+
+	// Changed while the bot is not running, so it catches up and reconciles posts on the next start
+	missed := []*discordgo.Message{b.send("fourth", testImage), b.send("fifth", testImage)}
+	if err := b.f.AddReaction(b.channel.ID, backfilled[0].ID, b.user, discordgo.Emoji{Name: "👍"}); err != nil {
+		t.Fatalf("failed to add reaction: %s", err)
+	}
+	if err := b.f.RemoveReaction(b.channel.ID, backfilled[1].ID, b.user, discordgo.Emoji{Name: "👍"}); err != nil {
+		t.Fatalf("failed to remove reaction: %s", err)
+	}
+	if err := b.f.EditMessage(b.channel.ID, backfilled[2].ID, "edited"); err != nil {
+		t.Fatalf("failed to edit message: %s", err)
+	}
+	b.start()
+	for _, m := range missed {
+		if p := b.post(m.ID); p.ID == 0 || p.Message != m.Content {
+			t.Errorf("expected missed message %s to be stored, got %+v", m.ID, p)
+		}
+	}
+	if n := b.reactions(backfilled[0].ID); n != 1 {
+		t.Errorf("expected missed reaction to be added, got %d reactions", n)
+	}
+	if n := b.reactions(backfilled[1].ID); n != 0 {
+		t.Errorf("expected removed reaction to be removed, got %d reactions", n)
+	}
+	if p := b.post(backfilled[2].ID); p.Message != "edited" || !p.EditedAt.Valid {
+		t.Errorf("expected missed edit to be stored, got %+v", p)
+	}
+}
+
+func TestBotHandlesEvents(t *testing.T) {
+	b := newTestBot(t)
+	b.start()
+	other := b.f.AddUser("other")
+	thumbsUp := discordgo.Emoji{Name: "👍"}
+
+	m := b.send("post", testImage)
+	b.waitFor("post to be created", func() bool { return b.post(m.ID).ID != 0 })
+
+	for _, u := range []*discordgo.User{b.user, other} {
+		if err := b.f.AddReaction(b.channel.ID, m.ID, u, thumbsUp); err != nil {
+			t.Fatalf("failed to add reaction: %s", err)
+		}
+	}
+	b.waitFor("reactions to be added", func() bool { return b.reactions(m.ID) == 2 })
+
+	if err := b.f.RemoveReaction(b.channel.ID, m.ID, other, thumbsUp); err != nil {
+		t.Fatalf("failed to remove reaction: %s", err)
+	}
+	b.waitFor("reaction to be removed", func() bool { return b.reactions(m.ID) == 1 })
+
+	if err := b.f.RemoveAllReactions(b.channel.ID, m.ID); err != nil {
+		t.Fatalf("failed to remove reactions: %s", err)
+	}
+	b.waitFor("all reactions to be removed", func() bool { return b.reactions(m.ID) == 0 })
+
+	if err := b.f.EditMessage(b.channel.ID, m.ID, "edited"); err != nil {
+		t.Fatalf("failed to edit message: %s", err)
+	}
+	b.waitFor("post to be updated", func() bool {
+		p := b.post(m.ID)
+		return p.Message == "edited" && p.EditedAt.Valid
+	})
+
+	if err := b.f.DeleteMessage(b.channel.ID, m.ID); err != nil {
+		t.Fatalf("failed to delete message: %s", err)
+	}
+	b.waitFor("post to be deleted", func() bool { return b.post(m.ID).ID == 0 })
+
+	bulk := []string{b.send("bulk", testImage).ID, b.send("bulk", testImage).ID}
+	b.waitFor("posts to be created", func() bool { return b.post(bulk[0]).ID != 0 && b.post(bulk[1]).ID != 0 })
+	if err := b.f.DeleteMessages(b.channel.ID, bulk...); err != nil {
+		t.Fatalf("failed to delete messages: %s", err)
+	}
+	b.waitFor("posts to be bulk-deleted", func() bool { return b.post(bulk[0]).ID == 0 && b.post(bulk[1]).ID == 0 })
+}
@@ -0,0 +1,750 @@
+// Package fake implements Discord kept in memory. It serves the REST calls used by the bot with Discord
+// pagination semantics and dispatches gateway events to handlers the way a discordgo session does, so
+// that synchronization and event handling can run offline, such as in tests against a local PostgreSQL.
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"pkg.mon.icu/monicu/internal/discord"
+)
+
+const (
+	// discordEpoch is the first millisecond of 2015 in Unix time, which snowflake timestamps count from.
+	discordEpoch = 1420070400000
+
+	defaultMessagesLimit  = 50
+	defaultReactionsLimit = 25
+	maxLimit              = 100
+)
+
+// Discord is an in-memory Discord with a single bot user, safe for concurrent use. Methods changing it,
+// such as SendMessage, dispatch the corresponding gateway event to handlers while the session is open,
+// and return once the handlers do.
+type Discord struct {
+	mu          sync.Mutex
+	lastID      uint64
+	bot         *discordgo.User
+	guilds      map[string]*discordgo.Guild
+	channels    map[string]*discordgo.Channel
+	messages    map[string][]*discordgo.Message
+	reactions   map[string]map[string][]*discordgo.User
+	permissions map[string]int64
+
+	handlersMu sync.Mutex
+	handlers   []*handler
+	open       bool
+}
+
+var _ discord.Session = (*Discord)(nil)
+
+type handler struct {
+	fn reflect.Value
+}
+
+// New creates an empty Discord with a bot user named bot.
+func New() *Discord {
+	f := &Discord{
+		guilds:      make(map[string]*discordgo.Guild),
+		channels:    make(map[string]*discordgo.Channel),
+		messages:    make(map[string][]*discordgo.Message),
+		reactions:   make(map[string]map[string][]*discordgo.User),
+		permissions: make(map[string]int64),
+	}
+	f.bot = &discordgo.User{ID: f.newID(), Username: "bot", Discriminator: "0000", Bot: true}
+	return f
+}
+
+// Bot returns the bot user, which is the author of replies.
+func (f *Discord) Bot() *discordgo.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return copyUser(f.bot)
+}
+
+// Gateway
+
+// AddHandler adds handler of events, which has to be a func(*discordgo.Session, *discordgo.SomeEvent).
+// Handlers are called with nil session. Returns function removing the handler.
+func (f *Discord) AddHandler(h interface{}) func() {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func || v.Type().NumIn() != 2 {
+		panic(fmt.Sprintf("invalid handler type %T", h))
+	}
+
+	f.handlersMu.Lock()
+	defer f.handlersMu.Unlock()
+	hh := &handler{fn: v}
+	f.handlers = append(f.handlers, hh)
+	return func() {
+		f.handlersMu.Lock()
+		defer f.handlersMu.Unlock()
+		for i, h := range f.handlers {
+			if h == hh {
+				f.handlers = append(f.handlers[:i], f.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Open opens the session, dispatching Ready followed by GuildCreate for every guild.
+func (f *Discord) Open() error {
+	f.handlersMu.Lock()
+	if f.open {
+		f.handlersMu.Unlock()
+		return discordgo.ErrWSAlreadyOpen
+	}
+	f.open = true
+	f.handlersMu.Unlock()
+
+	f.mu.Lock()
+	r := &discordgo.Ready{Version: 8, SessionID: "fake", User: copyUser(f.bot)}
+	creates := make([]interface{}, 0, len(f.guilds))
+	for _, g := range f.sortedGuilds() {
+		r.Guilds = append(r.Guilds, &discordgo.Guild{ID: g.ID, Unavailable: true})
+		creates = append(creates, &discordgo.GuildCreate{Guild: f.copyGuild(g)})
+	}
+	f.mu.Unlock()
+
+	f.dispatch(r)
+	for _, e := range creates {
+		f.dispatch(e)
+	}
+	return nil
+}
+
+// Close closes the session, after which no events are dispatched.
+func (f *Discord) Close() error {
+	f.handlersMu.Lock()
+	defer f.handlersMu.Unlock()
+	f.open = false
+	return nil
+}
+
+// dispatch calls handlers accepting event e if the session is open. It must not be called with f.mu held,
+// as handlers make REST calls.
+func (f *Discord) dispatch(e interface{}) {
+	f.handlersMu.Lock()
+	if !f.open {
+		f.handlersMu.Unlock()
+		return
+	}
+	hs := append([]*handler(nil), f.handlers...)
+	f.handlersMu.Unlock()
+
+	ev := reflect.ValueOf(e)
+	for _, h := range hs {
+		t := h.fn.Type()
+		if ev.Type().AssignableTo(t.In(1)) {
+			h.fn.Call([]reflect.Value{reflect.Zero(t.In(0)), ev})
+		}
+	}
+}
+
+// Changes
+
+// AddGuild creates a guild the bot is a member of.
+func (f *Discord) AddGuild(name string) *discordgo.Guild {
+	f.mu.Lock()
+	g := &discordgo.Guild{ID: f.newID(), Name: name, OwnerID: f.bot.ID}
+	f.guilds[g.ID] = g
+	e := &discordgo.GuildCreate{Guild: f.copyGuild(g)}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return e.Guild
+}
+
+// LeaveGuild removes the bot from guild with the specified ID, deleting the guild with its channels.
+func (f *Discord) LeaveGuild(guildID string) error {
+	f.mu.Lock()
+	g, ok := f.guilds[guildID]
+	if !ok {
+		f.mu.Unlock()
+		return notFound(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+	for _, ch := range g.Channels {
+		f.deleteChannel(ch.ID)
+	}
+	delete(f.guilds, guildID)
+	f.mu.Unlock()
+
+	f.dispatch(&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: guildID}})
+	return nil
+}
+
+// AddChannel creates a text channel in guild with the specified ID, in category with ID parentID unless
+// it is empty.
+func (f *Discord) AddChannel(guildID, parentID, name string) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	g, ok := f.guilds[guildID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, notFound(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+	ch := &discordgo.Channel{
+		ID:       f.newID(),
+		GuildID:  guildID,
+		Name:     name,
+		Type:     discordgo.ChannelTypeGuildText,
+		Position: len(g.Channels),
+		ParentID: parentID,
+	}
+	g.Channels = append(g.Channels, ch)
+	f.channels[ch.ID] = ch
+	e := &discordgo.ChannelCreate{Channel: copyChannel(ch)}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return e.Channel, nil
+}
+
+// UpdateChannel replaces name, topic, category and position of channel with ID ch.ID.
+func (f *Discord) UpdateChannel(ch *discordgo.Channel) error {
+	f.mu.Lock()
+	sc, ok := f.channels[ch.ID]
+	if !ok {
+		f.mu.Unlock()
+		return notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	sc.Name, sc.Topic, sc.ParentID, sc.Position = ch.Name, ch.Topic, ch.ParentID, ch.Position
+	e := &discordgo.ChannelUpdate{Channel: copyChannel(sc)}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// DeleteChannel deletes channel with the specified ID along with its messages.
+func (f *Discord) DeleteChannel(channelID string) error {
+	f.mu.Lock()
+	ch, ok := f.channels[channelID]
+	if !ok {
+		f.mu.Unlock()
+		return notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	g := f.guilds[ch.GuildID]
+	for i, gc := range g.Channels {
+		if gc.ID == channelID {
+			g.Channels = append(g.Channels[:i], g.Channels[i+1:]...)
+			break
+		}
+	}
+	e := &discordgo.ChannelDelete{Channel: copyChannel(ch)}
+	f.deleteChannel(channelID)
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+func (f *Discord) deleteChannel(channelID string) {
+	for _, m := range f.messages[channelID] {
+		delete(f.reactions, m.ID)
+	}
+	delete(f.messages, channelID)
+	delete(f.channels, channelID)
+}
+
+// AddUser creates a user, who can send messages and react to them in every channel.
+func (f *Discord) AddUser(username string) *discordgo.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &discordgo.User{ID: f.newID(), Username: username, Discriminator: "0001"}
+}
+
+// SetPermissions sets permissions of user with the specified ID in channel with the specified ID.
+func (f *Discord) SetPermissions(userID, channelID string, permissions int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.permissions[userID+"/"+channelID] = permissions
+}
+
+// SendMessage creates a message from author in channel with the specified ID. Attachments get IDs
+// assigned.
+func (f *Discord) SendMessage(channelID string, author *discordgo.User, content string, attachments ...*discordgo.MessageAttachment) (*discordgo.Message, error) {
+	f.mu.Lock()
+	ch, ok := f.channels[channelID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	m := f.newMessage(ch, author, content)
+	for _, a := range attachments {
+		a := *a
+		if a.ID == "" {
+			a.ID = f.newID()
+		}
+		m.Attachments = append(m.Attachments, &a)
+	}
+	f.messages[channelID] = append(f.messages[channelID], m)
+	e := &discordgo.MessageCreate{Message: f.eventMessage(m)}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return e.Message, nil
+}
+
+// EditMessage replaces content of message with the specified ID.
+func (f *Discord) EditMessage(channelID, messageID, content string) error {
+	f.mu.Lock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	m.Content = content
+	m.EditedTimestamp = discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano))
+	e := &discordgo.MessageUpdate{Message: f.eventMessage(m)}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// DeleteMessage deletes message with the specified ID.
+func (f *Discord) DeleteMessage(channelID, messageID string) error {
+	f.mu.Lock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	f.deleteMessage(channelID, messageID)
+	e := &discordgo.MessageDelete{Message: &discordgo.Message{ID: m.ID, ChannelID: m.ChannelID, GuildID: m.GuildID}}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// DeleteMessages deletes messages with the specified IDs at once, dispatching a single bulk delete.
+func (f *Discord) DeleteMessages(channelID string, messageIDs ...string) error {
+	f.mu.Lock()
+	ch, ok := f.channels[channelID]
+	if !ok {
+		f.mu.Unlock()
+		return notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	for _, ID := range messageIDs {
+		if _, err := f.message(channelID, ID); err != nil {
+			f.mu.Unlock()
+			return err
+		}
+	}
+	for _, ID := range messageIDs {
+		f.deleteMessage(channelID, ID)
+	}
+	e := &discordgo.MessageDeleteBulk{Messages: append([]string(nil), messageIDs...), ChannelID: channelID, GuildID: ch.GuildID}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+func (f *Discord) deleteMessage(channelID, messageID string) {
+	ms := f.messages[channelID]
+	for i, m := range ms {
+		if m.ID == messageID {
+			f.messages[channelID] = append(ms[:i], ms[i+1:]...)
+			break
+		}
+	}
+	delete(f.reactions, messageID)
+}
+
+// AddReaction adds reaction of user with emoji to message with the specified ID. Adding a reaction
+// already there does nothing.
+func (f *Discord) AddReaction(channelID, messageID string, user *discordgo.User, emoji discordgo.Emoji) error {
+	f.mu.Lock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	if f.reactions[messageID] == nil {
+		f.reactions[messageID] = make(map[string][]*discordgo.User)
+	}
+	users := f.reactions[messageID][emoji.APIName()]
+	i := sort.Search(len(users), func(i int) bool { return snowflake(users[i].ID) >= snowflake(user.ID) })
+	if i < len(users) && users[i].ID == user.ID {
+		f.mu.Unlock()
+		return nil
+	}
+	users = append(users, nil)
+	copy(users[i+1:], users[i:])
+	users[i] = copyUser(user)
+	f.reactions[messageID][emoji.APIName()] = users
+	f.countReactions(m)
+	e := &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{UserID: user.ID, MessageID: m.ID, Emoji: emoji, ChannelID: m.ChannelID, GuildID: m.GuildID}}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// RemoveReaction removes reaction of user with emoji from message with the specified ID. Removing
+// a reaction that is not there does nothing.
+func (f *Discord) RemoveReaction(channelID, messageID string, user *discordgo.User, emoji discordgo.Emoji) error {
+	f.mu.Lock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	users := f.reactions[messageID][emoji.APIName()]
+	removed := false
+	for i, u := range users {
+		if u.ID == user.ID {
+			users, removed = append(users[:i], users[i+1:]...), true
+			break
+		}
+	}
+	if !removed {
+		f.mu.Unlock()
+		return nil
+	}
+	if len(users) == 0 {
+		delete(f.reactions[messageID], emoji.APIName())
+	} else {
+		f.reactions[messageID][emoji.APIName()] = users
+	}
+	f.countReactions(m)
+	e := &discordgo.MessageReactionRemove{MessageReaction: &discordgo.MessageReaction{UserID: user.ID, MessageID: m.ID, Emoji: emoji, ChannelID: m.ChannelID, GuildID: m.GuildID}}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// RemoveAllReactions removes all reactions from message with the specified ID.
+func (f *Discord) RemoveAllReactions(channelID, messageID string) error {
+	f.mu.Lock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	delete(f.reactions, messageID)
+	f.countReactions(m)
+	e := &discordgo.MessageReactionRemoveAll{MessageReaction: &discordgo.MessageReaction{MessageID: m.ID, ChannelID: m.ChannelID, GuildID: m.GuildID}}
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return nil
+}
+
+// countReactions updates reaction counts of message from stored reactions, in order emojis were first
+// used.
+func (f *Discord) countReactions(m *discordgo.Message) {
+	counted := make([]*discordgo.MessageReactions, 0, len(m.Reactions)+1)
+	seen := make(map[string]bool, len(m.Reactions)+1)
+	for _, r := range m.Reactions {
+		if users := f.reactions[m.ID][r.Emoji.APIName()]; len(users) > 0 {
+			counted = append(counted, &discordgo.MessageReactions{Count: len(users), Me: containsUser(users, f.bot.ID), Emoji: r.Emoji})
+			seen[r.Emoji.APIName()] = true
+		}
+	}
+	for name, users := range f.reactions[m.ID] {
+		if !seen[name] {
+			em := emojiFromAPIName(name)
+			counted = append(counted, &discordgo.MessageReactions{Count: len(users), Me: containsUser(users, f.bot.ID), Emoji: &em})
+		}
+	}
+	m.Reactions = counted
+}
+
+// REST calls
+
+// Channel returns channel with the specified ID.
+func (f *Discord) Channel(channelID string) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.channels[channelID]
+	if !ok {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	return copyChannel(ch), nil
+}
+
+// GuildChannels returns channels of guild with the specified ID.
+func (f *Discord) GuildChannels(guildID string) ([]*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil, notFound(discordgo.ErrCodeUnknownGuild, "Unknown Guild")
+	}
+
+	chans := make([]*discordgo.Channel, 0, len(g.Channels))
+	for _, ch := range g.Channels {
+		chans = append(chans, copyChannel(ch))
+	}
+	return chans, nil
+}
+
+// ChannelMessages returns up to limit messages of channel with the specified ID (50 if limit is zero, at
+// most 100), newest first. Messages are the ones right before beforeID, right after afterID or around
+// aroundID, whichever is set, or the newest ones if none is.
+func (f *Discord) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.channels[channelID]; !ok {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	limit = clampLimit(limit, defaultMessagesLimit)
+
+	// Stored oldest first, as IDs increase
+	ms := f.messages[channelID]
+	var from, to int
+	switch {
+	case aroundID != "":
+		i := sort.Search(len(ms), func(i int) bool { return snowflake(ms[i].ID) >= snowflake(aroundID) })
+		from, to = i-limit/2, i-limit/2+limit
+	case afterID != "":
+		from = sort.Search(len(ms), func(i int) bool { return snowflake(ms[i].ID) > snowflake(afterID) })
+		to = from + limit
+	case beforeID != "":
+		to = sort.Search(len(ms), func(i int) bool { return snowflake(ms[i].ID) >= snowflake(beforeID) })
+		from = to - limit
+	default:
+		to = len(ms)
+		from = to - limit
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(ms) {
+		to = len(ms)
+	}
+
+	page := make([]*discordgo.Message, 0, to-from)
+	for i := to - 1; i >= from; i-- {
+		page = append(page, restMessage(ms[i]))
+	}
+	return page, nil
+}
+
+// ChannelMessage returns message with the specified ID.
+func (f *Discord) ChannelMessage(channelID, messageID string) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.message(channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	return restMessage(m), nil
+}
+
+// ChannelMessageSendComplex sends a message from the bot, dispatching MessageCreate like any other
+// message. Only content and reference of data are used.
+func (f *Discord) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	f.mu.Lock()
+	ch, ok := f.channels[channelID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	m := f.newMessage(ch, f.bot, data.Content)
+	if data.Reference != nil {
+		ref := *data.Reference
+		m.Type, m.MessageReference = discordgo.MessageTypeReply, &ref
+	}
+	f.messages[channelID] = append(f.messages[channelID], m)
+	e := &discordgo.MessageCreate{Message: f.eventMessage(m)}
+	reply := restMessage(m)
+	f.mu.Unlock()
+
+	f.dispatch(e)
+	return reply, nil
+}
+
+// MessageReactions returns up to limit users who reacted to message with the specified ID with emoji
+// named emojiID (25 if limit is zero, at most 100), ordered by ID, after afterID and before beforeID if
+// they are set.
+func (f *Discord) MessageReactions(channelID, messageID, emojiID string, limit int, beforeID, afterID string) ([]*discordgo.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.message(channelID, messageID); err != nil {
+		return nil, err
+	}
+	limit = clampLimit(limit, defaultReactionsLimit)
+
+	users := make([]*discordgo.User, 0, limit)
+	for _, u := range f.reactions[messageID][emojiID] {
+		if len(users) == limit {
+			break
+		}
+		if afterID != "" && snowflake(u.ID) <= snowflake(afterID) {
+			continue
+		}
+		if beforeID != "" && snowflake(u.ID) >= snowflake(beforeID) {
+			break
+		}
+		users = append(users, copyUser(u))
+	}
+	return users, nil
+}
+
+// UserChannelPermissions returns permissions of user with the specified ID in channel with the specified
+// ID, set with SetPermissions.
+func (f *Discord) UserChannelPermissions(userID, channelID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.channels[channelID]; !ok {
+		return 0, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	return f.permissions[userID+"/"+channelID], nil
+}
+
+// Helpers, methods of which must be called with f.mu held
+
+// newID returns a new snowflake, greater than all previous ones, with timestamp of the current time.
+func (f *Discord) newID() string {
+	ID := uint64(time.Now().UnixNano()/int64(time.Millisecond)-discordEpoch) << 22
+	if ID <= f.lastID {
+		ID = f.lastID + 1
+	}
+	f.lastID = ID
+	return strconv.FormatUint(ID, 10)
+}
+
+func (f *Discord) newMessage(ch *discordgo.Channel, author *discordgo.User, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        f.newID(),
+		ChannelID: ch.ID,
+		GuildID:   ch.GuildID,
+		Content:   content,
+		Timestamp: discordgo.Timestamp(time.Now().UTC().Format(time.RFC3339Nano)),
+		Author:    copyUser(author),
+		Type:      discordgo.MessageTypeDefault,
+	}
+}
+
+func (f *Discord) message(channelID, messageID string) (*discordgo.Message, error) {
+	if _, ok := f.channels[channelID]; !ok {
+		return nil, notFound(discordgo.ErrCodeUnknownChannel, "Unknown Channel")
+	}
+	for _, m := range f.messages[channelID] {
+		if m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, notFound(discordgo.ErrCodeUnknownMessage, "Unknown Message")
+}
+
+// eventMessage copies message for a gateway event, which has member of the author attached.
+func (f *Discord) eventMessage(m *discordgo.Message) *discordgo.Message {
+	em := copyMessage(m)
+	em.Member = &discordgo.Member{GuildID: m.GuildID}
+	return em
+}
+
+func (f *Discord) sortedGuilds() []*discordgo.Guild {
+	guilds := make([]*discordgo.Guild, 0, len(f.guilds))
+	for _, g := range f.guilds {
+		guilds = append(guilds, g)
+	}
+	sort.Slice(guilds, func(a, b int) bool { return snowflake(guilds[a].ID) < snowflake(guilds[b].ID) })
+	return guilds
+}
+
+func (f *Discord) copyGuild(g *discordgo.Guild) *discordgo.Guild {
+	c := *g
+	c.Channels = make([]*discordgo.Channel, 0, len(g.Channels))
+	for _, ch := range g.Channels {
+		c.Channels = append(c.Channels, copyChannel(ch))
+	}
+	return &c
+}
+
+// restMessage copies message as returned by REST calls, which lack guild ID.
+func restMessage(m *discordgo.Message) *discordgo.Message {
+	c := copyMessage(m)
+	c.GuildID = ""
+	return c
+}
+
+func copyMessage(m *discordgo.Message) *discordgo.Message {
+	c := *m
+	c.Author = copyUser(m.Author)
+	c.Attachments = make([]*discordgo.MessageAttachment, 0, len(m.Attachments))
+	for _, a := range m.Attachments {
+		a := *a
+		c.Attachments = append(c.Attachments, &a)
+	}
+	c.Reactions = make([]*discordgo.MessageReactions, 0, len(m.Reactions))
+	for _, r := range m.Reactions {
+		r := *r
+		em := *r.Emoji
+		r.Emoji = &em
+		c.Reactions = append(c.Reactions, &r)
+	}
+	c.Embeds = append([]*discordgo.MessageEmbed(nil), m.Embeds...)
+	return &c
+}
+
+func copyChannel(ch *discordgo.Channel) *discordgo.Channel {
+	c := *ch
+	return &c
+}
+
+func copyUser(u *discordgo.User) *discordgo.User {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	return &c
+}
+
+func containsUser(users []*discordgo.User, ID string) bool {
+	for _, u := range users {
+		if u.ID == ID {
+			return true
+		}
+	}
+	return false
+}
+
+// emojiFromAPIName parses emoji name as used in REST calls (see discordgo.Emoji.APIName.)
+func emojiFromAPIName(name string) discordgo.Emoji {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == ':' {
+			return discordgo.Emoji{Name: name[:i], ID: name[i+1:]}
+		}
+	}
+	return discordgo.Emoji{Name: name}
+}
+
+func clampLimit(limit, def int) int {
+	if limit <= 0 {
+		return def
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// snowflake parses ID, ordering malformed IDs first.
+func snowflake(ID string) uint64 {
+	s, _ := strconv.ParseUint(ID, 10, 64)
+	return s
+}
+
+// notFound returns error Discord responds with when a resource does not exist.
+func notFound(code int, message string) error {
+	return &discordgo.RESTError{
+		Response:     &http.Response{Status: "404 Not Found", StatusCode: http.StatusNotFound},
+		ResponseBody: []byte(fmt.Sprintf(`{"message": %q, "code": %d}`, message, code)),
+		Message:      &discordgo.APIErrorMessage{Code: code, Message: message},
+	}
+}
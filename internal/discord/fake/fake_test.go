@@ -0,0 +1,153 @@
+package fake
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newTestChannel creates a channel with n messages, returned oldest first.
+func newTestChannel(t *testing.T, f *Discord, n int) (*discordgo.Channel, []*discordgo.Message) {
+	t.Helper()
+	ch, err := f.AddChannel(f.AddGuild("guild").ID, "", "channel")
+	if err != nil {
+		t.Fatalf("failed to add channel: %s", err)
+	}
+	u := f.AddUser("user")
+	ms := make([]*discordgo.Message, 0, n)
+	for i := 0; i < n; i++ {
+		m, err := f.SendMessage(ch.ID, u, "message")
+		if err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		ms = append(ms, m)
+	}
+	return ch, ms
+}
+
+func messageIDs(ms []*discordgo.Message) []string {
+	IDs := make([]string, 0, len(ms))
+	for _, m := range ms {
+		IDs = append(IDs, m.ID)
+	}
+	return IDs
+}
+
+func isNotFound(err error, code int) bool {
+	var rerr *discordgo.RESTError
+	return errors.As(err, &rerr) && rerr.Message != nil && rerr.Message.Code == code
+}
+
+func TestChannelMessages(t *testing.T) {
+	f := New()
+	ch, ms := newTestChannel(t, f, 10)
+	// newest returns IDs of messages with the specified indices, which are expected newest first
+	newest := func(is ...int) []string {
+		IDs := make([]string, 0, len(is))
+		for _, i := range is {
+			IDs = append(IDs, ms[i].ID)
+		}
+		return IDs
+	}
+
+	tests := []struct {
+		name                        string
+		limit                       int
+		beforeID, afterID, aroundID string
+		want                        []string
+	}{
+		{"newest", 3, "", "", "", newest(9, 8, 7)},
+		{"default limit", 0, "", "", "", newest(9, 8, 7, 6, 5, 4, 3, 2, 1, 0)},
+		{"limit over maximum", 200, "", "", "", newest(9, 8, 7, 6, 5, 4, 3, 2, 1, 0)},
+		{"before", 3, ms[5].ID, "", "", newest(4, 3, 2)},
+		{"before first page", 3, ms[1].ID, "", "", newest(0)},
+		{"before oldest", 3, ms[0].ID, "", "", newest()},
+		{"after", 3, "", ms[5].ID, "", newest(8, 7, 6)},
+		{"after last page", 3, "", ms[8].ID, "", newest(9)},
+		{"after newest", 3, "", ms[9].ID, "", newest()},
+		{"around", 3, "", "", ms[5].ID, newest(6, 5, 4)},
+		{"around oldest", 4, "", "", ms[0].ID, newest(1, 0)},
+		{"around newest", 4, "", "", ms[9].ID, newest(9, 8, 7)},
+	}
+	for _, tt := range tests {
+		page, err := f.ChannelMessages(ch.ID, tt.limit, tt.beforeID, tt.afterID, tt.aroundID)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		if got := messageIDs(page); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected messages %v, got %v", tt.name, tt.want, got)
+		}
+		for _, m := range page {
+			if m.GuildID != "" {
+				t.Errorf("%s: expected messages without guild ID, got %q", tt.name, m.GuildID)
+			}
+		}
+	}
+
+	if _, err := f.ChannelMessages("1", 3, "", "", ""); !isNotFound(err, discordgo.ErrCodeUnknownChannel) {
+		t.Errorf("expected unknown channel error, got %v", err)
+	}
+}
+
+func TestMessageReactions(t *testing.T) {
+	f := New()
+	ch, ms := newTestChannel(t, f, 1)
+	emoji := discordgo.Emoji{ID: "100", Name: "custom"}
+	users := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		u := f.AddUser("user")
+		if err := f.AddReaction(ch.ID, ms[0].ID, u, emoji); err != nil {
+			t.Fatalf("failed to add reaction: %s", err)
+		}
+		users = append(users, u.ID)
+	}
+	if err := f.AddReaction(ch.ID, ms[0].ID, f.Bot(), discordgo.Emoji{Name: "👍"}); err != nil {
+		t.Fatalf("failed to add reaction: %s", err)
+	}
+
+	tests := []struct {
+		name              string
+		emojiID           string
+		limit             int
+		beforeID, afterID string
+		want              []string
+	}{
+		{"first page", emoji.APIName(), 2, "", "", users[:2]},
+		{"default limit", emoji.APIName(), 0, "", "", users},
+		{"after", emoji.APIName(), 2, "", users[1], users[2:4]},
+		{"after last", emoji.APIName(), 2, "", users[4], []string{}},
+		{"before", emoji.APIName(), 0, users[3], "", users[:3]},
+		{"between", emoji.APIName(), 0, users[3], users[0], users[1:3]},
+		{"other emoji", "👍", 0, "", "", []string{f.Bot().ID}},
+		{"unused emoji", "👎", 0, "", "", []string{}},
+	}
+	for _, tt := range tests {
+		page, err := f.MessageReactions(ch.ID, ms[0].ID, tt.emojiID, tt.limit, tt.beforeID, tt.afterID)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+			continue
+		}
+		got := make([]string, 0, len(page))
+		for _, u := range page {
+			got = append(got, u.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected users %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	m, err := f.ChannelMessage(ch.ID, ms[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get message: %s", err)
+	}
+	if len(m.Reactions) != 2 || m.Reactions[0].Count != 5 || m.Reactions[0].Me || m.Reactions[1].Count != 1 || !m.Reactions[1].Me {
+		t.Errorf("unexpected reaction counts %+v", m.Reactions)
+	}
+
+	if _, err := f.MessageReactions(ch.ID, "1", emoji.APIName(), 0, "", ""); !isNotFound(err, discordgo.ErrCodeUnknownMessage) {
+		t.Errorf("expected unknown message error, got %v", err)
+	}
+}
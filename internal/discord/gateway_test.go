@@ -0,0 +1,124 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// gatewayPayload is a gateway packet sent by Discord.
+type gatewayPayload struct {
+	Op       int         `json:"op"`
+	Sequence int         `json:"s,omitempty"`
+	Type     string      `json:"t,omitempty"`
+	Data     interface{} `json:"d"`
+}
+
+// serveGateway serves a gateway that sends READY followed by the specified events, with REST endpoint
+// returning its URL at /gateway.
+func serveGateway(t *testing.T, events []gatewayPayload) *httptest.Server {
+	var srv *httptest.Server
+	upgrader := websocket.Upgrader{}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gateway" {
+			fmt.Fprintf(w, `{"url": %q}`, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+			return
+		}
+
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade connection: %s", err)
+			return
+		}
+		defer c.Close()
+
+		_ = c.WriteJSON(gatewayPayload{Op: 10, Data: map[string]interface{}{"heartbeat_interval": 45000}})
+		if _, _, err := c.ReadMessage(); err != nil {
+			t.Errorf("failed to read identify: %s", err)
+			return
+		}
+		_ = c.WriteJSON(gatewayPayload{Op: 0, Sequence: 1, Type: "READY", Data: map[string]interface{}{"v": 8, "session_id": "test", "user": map[string]string{"id": "1"}}})
+		for i, e := range events {
+			e.Sequence = i + 2
+			if err := c.WriteJSON(e); err != nil {
+				t.Errorf("failed to send event: %s", err)
+				return
+			}
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	return srv
+}
+
+func TestGatewayEventsQueuedInOrder(t *testing.T) {
+	const guildID, channelID, messages = 10, 20, 200
+
+	events := make([]gatewayPayload, 0, 2*messages)
+	for i := 0; i < messages; i++ {
+		ID := strconv.Itoa(1000 + i)
+		events = append(events,
+			gatewayPayload{Type: "MESSAGE_CREATE", Data: map[string]interface{}{"id": ID, "channel_id": "20", "guild_id": "10", "content": "post", "author": map[string]string{"id": "2"}}},
+			gatewayPayload{Type: "MESSAGE_REACTION_ADD", Data: map[string]interface{}{"user_id": "3", "message_id": ID, "channel_id": "20", "guild_id": "10", "emoji": map[string]string{"name": "x"}}},
+		)
+	}
+	srv := serveGateway(t, events)
+	defer srv.Close()
+
+	endpoint := discordgo.EndpointGateway
+	discordgo.EndpointGateway = srv.URL + "/gateway"
+	defer func() { discordgo.EndpointGateway = endpoint }()
+
+	d, err := NewDiscord(context.Background(), zap.NewNop().Sugar(), "Bot test", newTestConfig(t), nil)
+	if err != nil {
+		t.Fatalf("failed to create Discord: %s", err)
+	}
+	prepareTestDiscord(t, d, []uint64{guildID}, []uint64{channelID})
+	// Skip building caches, which needs storage
+	d.initOnce.Do(func() {})
+
+	d.addHandlers()
+	if err := d.session.Open(); err != nil {
+		t.Fatalf("failed to open session: %s", err)
+	}
+	defer d.session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 2*messages; i++ {
+		r, err := d.queue.Next(ctx)
+		if err != nil {
+			t.Fatalf("failed to read event %d: %s", i, err)
+		}
+		e, err := decodeEvent(r.Type, r.Data)
+		if err != nil {
+			t.Fatalf("failed to decode event %d: %s", i, err)
+		}
+
+		ID := strconv.Itoa(1000 + i/2)
+		switch e := e.(type) {
+		case *discordgo.MessageCreate:
+			if i%2 != 0 || e.ID != ID {
+				t.Fatalf("event %d is MessageCreate of message %s, expected event of message %s", i, e.ID, ID)
+			}
+		case *discordgo.MessageReactionAdd:
+			if i%2 != 1 || e.MessageID != ID {
+				t.Fatalf("event %d is MessageReactionAdd of message %s, expected event of message %s", i, e.MessageID, ID)
+			}
+		default:
+			t.Fatalf("event %d has unexpected type %T", i, e)
+		}
+	}
+}
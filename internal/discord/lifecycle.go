@@ -0,0 +1,206 @@
+package discord
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// DeletePolicy decides what happens to stored posts of a channel or guild deleted in Discord (or which
+// the bot was removed from.)
+type DeletePolicy string
+
+const (
+	// DeleteKeep only stops tracking, keeping the channel and its posts as they are.
+	DeleteKeep DeletePolicy = "keep"
+	// DeleteArchive stops tracking and marks the channel as archived, keeping its posts.
+	DeleteArchive DeletePolicy = "archive"
+	// DeletePurge stops tracking and deletes the channel along with its posts.
+	DeletePurge DeletePolicy = "purge"
+)
+
+// ParseDeletePolicy parses delete policy name.
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch p := DeletePolicy(s); p {
+	case DeleteKeep, DeleteArchive, DeletePurge:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown delete policy %q", s)
+	}
+}
+
+// isTextChannel checks if channel is a guild channel containing messages.
+func isTextChannel(ch *discordgo.Channel) bool {
+	return ch.Type == discordgo.ChannelTypeGuildText || ch.Type == discordgo.ChannelTypeGuildNews
+}
+
+// matchesAutoTrack checks if channel is in one of the categories or has name matching the pattern that
+// are configured to be tracked automatically.
+func (d *Discord) matchesAutoTrack(ch *discordgo.Channel) bool {
+	if !isTextChannel(ch) {
+		return false
+	}
+	if p, err := model.ParseSnowflake(ch.ParentID); err == nil && d.config.autoTrackCategories.Contains(p) {
+		return true
+	}
+	return d.config.autoTrackRegexp != nil && d.config.autoTrackRegexp.MatchString(ch.Name)
+}
+
+// autoTrack starts tracking channel of a tracked guild if it matches the auto-track policy. Channels
+// tracking of which was stopped before are not tracked again.
+func (d *Discord) autoTrack(ch *discordgo.Channel, guildID uint64) {
+	c, err := model.ParseSnowflake(ch.ID)
+	if err != nil {
+		d.logger.Warnf("Ignoring channel with malformed ID %q.", ch.ID)
+		return
+	}
+	d.setChannelGuild(c, guildID)
+	if d.chans.Contains(c) || !d.matchesAutoTrack(ch) {
+		return
+	}
+
+	var seeded bool
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		if seeded, err = model.SeedTrackedChannel(d.ctx, tx, c); err != nil || !seeded {
+			return err
+		}
+		return model.NotifyTrackingChanged(d.ctx, tx)
+	}); err != nil {
+		d.logger.Errorf("Failed to auto-track channel %s: %s.", d.describeID(ch.ID), err)
+		return
+	}
+
+	if seeded {
+		d.logger.Infof("Auto-tracking channel %s.", d.describeID(ch.ID))
+		d.refreshTracking()
+	}
+}
+
+// onGuildCreate is called when a guild becomes available, either on connection or after the bot joins it,
+// and picks up its channels matching the auto-track policy.
+func (d *Discord) onGuildCreate(_ *discordgo.Session, e *discordgo.GuildCreate) {
+	g, tracked := d.trackedGuild(e.ID)
+	if !tracked {
+		return
+	}
+
+	d.storeGuild(e.Guild)
+	for _, ch := range e.Channels {
+		d.storeChannel(ch)
+		d.autoTrack(ch, g)
+	}
+}
+
+// onGuildDelete is called when a guild becomes unavailable or the bot is removed from it. Only the latter
+// applies the delete policy to the guild.
+func (d *Discord) onGuildDelete(_ *discordgo.Session, e *discordgo.GuildDelete) {
+	g, tracked := d.trackedGuild(e.ID)
+	if !tracked {
+		return
+	}
+	if e.Unavailable {
+		d.logger.Warnf("Guild %s became unavailable.", d.describeID(e.ID))
+		return
+	}
+
+	d.logger.Infof("Removed from guild %s, applying %s policy.", d.describeID(e.ID), d.config.deletePolicy)
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		if err := model.SetGuildTracked(d.ctx, tx, g, false); err != nil {
+			return fmt.Errorf("failed to stop tracking guild: %w", err)
+		}
+		if _, err := model.UntrackGuildChannels(d.ctx, tx, g); err != nil {
+			return fmt.Errorf("failed to stop tracking channels: %w", err)
+		}
+
+		gm := model.WrapGuildID(e.ID)
+		switch d.config.deletePolicy {
+		case DeleteArchive:
+			if _, err := model.ArchiveGuildChannels(d.ctx, tx, gm); err != nil {
+				return fmt.Errorf("failed to archive channels: %w", err)
+			}
+		case DeletePurge:
+			if _, err := model.DeleteGuild(d.ctx, tx, gm); err != nil {
+				return fmt.Errorf("failed to delete guild: %w", err)
+			}
+		}
+		return model.NotifyTrackingChanged(d.ctx, tx)
+	}); err != nil {
+		d.logger.Errorf("Failed to remove guild %s: %s.", d.describeID(e.ID), err)
+		return
+	}
+
+	d.refreshTracking()
+}
+
+func (d *Discord) onChannelCreate(_ *discordgo.Session, e *discordgo.ChannelCreate) {
+	g, tracked := d.trackedGuild(e.GuildID)
+	if !tracked {
+		return
+	}
+	d.storeChannel(e.Channel)
+	d.autoTrack(e.Channel, g)
+}
+
+// onChannelUpdate picks up channels moved into an auto-tracked category or renamed to match the pattern.
+// Channels no longer matching the policy keep being tracked.
+func (d *Discord) onChannelUpdate(_ *discordgo.Session, e *discordgo.ChannelUpdate) {
+	g, tracked := d.trackedGuild(e.GuildID)
+	if !tracked {
+		return
+	}
+	d.storeChannel(e.Channel)
+	d.autoTrack(e.Channel, g)
+}
+
+// onChannelDelete applies the delete policy to a deleted channel that has ever been tracked.
+func (d *Discord) onChannelDelete(_ *discordgo.Session, e *discordgo.ChannelDelete) {
+	if _, tracked := d.trackedGuild(e.GuildID); !tracked || !isTextChannel(e.Channel) {
+		return
+	}
+
+	c, err := model.ParseSnowflake(e.ID)
+	if err != nil {
+		d.logger.Warnf("Ignoring deletion of channel with malformed ID %q.", e.ID)
+		return
+	}
+	tracked := d.chans.Contains(c)
+	cm := model.WrapChannelID(e.ID)
+	var removed bool
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		if tracked {
+			if err := model.SetChannelTracked(d.ctx, tx, &model.TrackedChannel{DiscordID: c}, false); err != nil {
+				return fmt.Errorf("failed to stop tracking channel: %w", err)
+			}
+		}
+
+		var err error
+		switch d.config.deletePolicy {
+		case DeleteArchive:
+			if removed, err = model.SetChannelArchived(d.ctx, tx, cm, true); err != nil {
+				return fmt.Errorf("failed to archive channel: %w", err)
+			}
+		case DeletePurge:
+			if removed, err = model.DeleteChannel(d.ctx, tx, cm); err != nil {
+				return fmt.Errorf("failed to delete channel: %w", err)
+			}
+		}
+
+		if !tracked {
+			return nil
+		}
+		return model.NotifyTrackingChanged(d.ctx, tx)
+	}); err != nil {
+		d.logger.Errorf("Failed to remove channel %s: %s.", d.describeID(e.ID), err)
+		return
+	}
+
+	if removed {
+		d.logger.Infof("Channel %s was deleted, applied %s policy.", d.describeID(e.ID), d.config.deletePolicy)
+	}
+	if tracked {
+		d.refreshTracking()
+	}
+}
@@ -0,0 +1,97 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// setName caches name of guild or channel with the specified ID for use in logs.
+func (d *Discord) setName(ID uint64, name string) {
+	d.namesMu.Lock()
+	defer d.namesMu.Unlock()
+	d.names[ID] = name
+}
+
+// describe returns name of guild or channel with the specified ID followed by the ID, or just the ID if
+// the name is not known.
+func (d *Discord) describe(ID uint64) string {
+	d.namesMu.RLock()
+	name, ok := d.names[ID]
+	d.namesMu.RUnlock()
+	if !ok {
+		return strconv.FormatUint(ID, 10)
+	}
+	return fmt.Sprintf("%s (%d)", name, ID)
+}
+
+// describeID is describe for string IDs.
+func (d *Discord) describeID(ID string) string {
+	if i, err := strconv.ParseUint(ID, 10, 64); err == nil {
+		return d.describe(i)
+	}
+	return ID
+}
+
+// storeGuild stores name of the specified guild if it is in database.
+func (d *Discord) storeGuild(g *discordgo.Guild) {
+	if g.Name == "" { // unavailable guild
+		return
+	}
+	ID, err := model.ParseSnowflake(g.ID)
+	if err != nil {
+		d.logger.Warnf("Ignoring guild with malformed ID %q.", g.ID)
+		return
+	}
+	d.setName(ID, g.Name)
+
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		_, err := model.UpdateGuildName(d.ctx, tx, model.WrapGuildID(g.ID), g.Name)
+		return err
+	}); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to store guild %s: %s.", d.describeID(g.ID), err)
+	}
+}
+
+// storeChannel stores metadata of the specified channel if it is in database.
+func (d *Discord) storeChannel(ch *discordgo.Channel) {
+	ID, err := model.ParseSnowflake(ch.ID)
+	if err != nil {
+		d.logger.Warnf("Ignoring channel with malformed ID %q.", ch.ID)
+		return
+	}
+	d.setName(ID, "#"+ch.Name)
+	if !isTextChannel(ch) {
+		return
+	}
+
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		_, err := model.UpdateChannelMetadata(d.ctx, tx, model.WrapChannelID(ch.ID), model.WrapDiscordChannel(ch))
+		return err
+	}); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to store channel %s: %s.", d.describeID(ch.ID), err)
+	}
+}
+
+// storeStateMetadata stores metadata of the specified channel and guild from the state cache, if they
+// are there.
+func (d *Discord) storeStateMetadata(chanID, guildID uint64) {
+	if g, err := d.state().Guild(strconv.FormatUint(guildID, 10)); err == nil {
+		d.storeGuild(g)
+	}
+	if ch, err := d.state().Channel(strconv.FormatUint(chanID, 10)); err == nil {
+		d.storeChannel(ch)
+	}
+}
+
+func (d *Discord) onGuildUpdate(_ *discordgo.Session, e *discordgo.GuildUpdate) {
+	if _, tracked := d.trackedGuild(e.ID); !tracked {
+		return
+	}
+	d.storeGuild(e.Guild)
+}
@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// updateUserProfile stores profile of Discord user um was created from.
+func (d *Discord) updateUserProfile(tx pgx.Tx, um *model.User, u *discordgo.User) error {
+	if u.Username == "" { // partial user, such as one of a reaction event
+		return nil
+	}
+
+	changed, err := model.UpdateUserProfile(d.ctx, tx, um, model.WrapDiscordUser(u))
+	if err != nil {
+		return fmt.Errorf("failed to update profile of user %s: %w", u.ID, err)
+	}
+	if changed {
+		d.logger.Debugf("Recorded profile of user %s (%s#%s).", u.ID, u.Username, u.Discriminator)
+	}
+	return nil
+}
+
+// updateMemberNickname stores nickname of user um in guild gm.
+func (d *Discord) updateMemberNickname(tx pgx.Tx, um *model.User, gm *model.Guild, nickname string) error {
+	changed, err := model.UpdateMemberNickname(d.ctx, tx, um, gm, nickname)
+	if err != nil {
+		return fmt.Errorf("failed to update nickname of user %d: %w", um.DiscordID, err)
+	}
+	if changed {
+		d.logger.Debugf("Recorded nickname of user %d in guild %d.", um.DiscordID, gm.DiscordID)
+	}
+	return nil
+}
+
+// onGuildMemberUpdate records profile and nickname changes of members of tracked guilds. The event is only
+// received with Discord.MemberEvents enabled, since it requires the privileged server members intent.
+func (d *Discord) onGuildMemberUpdate(_ *discordgo.Session, e *discordgo.GuildMemberUpdate) {
+	if _, tracked := d.trackedGuild(e.GuildID); !tracked || e.User == nil {
+		return
+	}
+
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		gm := model.WrapGuildID(e.GuildID)
+		if err := model.FindGuild(d.ctx, tx, gm); err != nil {
+			return fmt.Errorf("failed to find guild: %w", err)
+		}
+		if gm.ID == 0 {
+			return nil
+		}
+
+		um := model.WrapUserID(e.User.ID)
+		if err := model.FindOrCreateUser(d.ctx, tx, um); err != nil {
+			return fmt.Errorf("failed to find or create user: %w", err)
+		}
+		if err := d.updateUserProfile(tx, um, e.User); err != nil {
+			return err
+		}
+		return d.updateMemberNickname(tx, um, gm, e.Nick)
+	}); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to update member: %s.", err)
+	}
+}
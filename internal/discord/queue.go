@@ -0,0 +1,267 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"pkg.mon.icu/monicu/internal/journal"
+)
+
+const (
+	// queueMinDelay and queueMaxDelay bound the exponential backoff between attempts to apply an event.
+	queueMinDelay = time.Second
+	queueMaxDelay = time.Minute
+)
+
+// queuedEvents creates empty events of types that are queued, by their type name.
+var queuedEvents = map[string]func() interface{}{
+	"MessageCreate":            func() interface{} { return &discordgo.MessageCreate{} },
+	"MessageUpdate":            func() interface{} { return &discordgo.MessageUpdate{} },
+	"MessageDelete":            func() interface{} { return &discordgo.MessageDelete{} },
+	"MessageDeleteBulk":        func() interface{} { return &discordgo.MessageDeleteBulk{} },
+	"MessageReactionAdd":       func() interface{} { return &discordgo.MessageReactionAdd{} },
+	"MessageReactionRemove":    func() interface{} { return &discordgo.MessageReactionRemove{} },
+	"MessageReactionRemoveAll": func() interface{} { return &discordgo.MessageReactionRemoveAll{} },
+}
+
+// eventType returns type name of event, such as MessageCreate.
+func eventType(e interface{}) string {
+	return reflect.TypeOf(e).Elem().Name()
+}
+
+// decodeEvent decodes JSON-encoded event of the specified type (see queuedEvents.)
+func decodeEvent(typ string, data []byte) (interface{}, error) {
+	newEvent, ok := queuedEvents[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %s", typ)
+	}
+
+	e := newEvent()
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// enqueue appends event to the queue, from which it is applied to database by the queue consumer. If the
+// queue cannot be written to, the event is applied right away.
+func (d *Discord) enqueue(e interface{}) {
+	err := d.queue.Append(eventType(e), e)
+	if err == nil {
+		return
+	}
+
+	d.logger.Errorf("Failed to queue %s event, applying it directly: %s.", eventType(e), err)
+	err = d.safeApplyEvent(eventType(e), e)
+	switch {
+	case err == nil:
+	case errors.Is(err, errPostNotStored):
+		d.logger.Debugf("Skipping %s event: %s.", eventType(e), err)
+	case d.ctx.Err() != nil:
+		d.logger.Errorf("Failed to apply %s event: %s.", eventType(e), err)
+	default:
+		d.storeFailedEvent(eventType(e), e, 1, err)
+	}
+}
+
+// applyEvent stores changes of event (see queuedEvents) in database.
+func (d *Discord) applyEvent(e interface{}) error {
+	switch e := e.(type) {
+	case *discordgo.MessageCreate:
+		return d.tryCreatePost(e.Message, priorityLive)
+	case *discordgo.MessageUpdate:
+		return d.tryUpdatePost(e.Message)
+	case *discordgo.MessageDelete:
+		return d.tryDeletePost(e.Message)
+	case *discordgo.MessageDeleteBulk:
+		if len(e.Messages) == 0 {
+			return nil
+		}
+		d.logger.Debugf("Bulk-deleting posts %s-%s.", e.Messages[0], e.Messages[len(e.Messages)-1])
+		for _, m := range e.Messages {
+			if err := d.tryDeletePost(&discordgo.Message{ID: m}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *discordgo.MessageReactionAdd:
+		return d.tryAddReaction(e.MessageReaction)
+	case *discordgo.MessageReactionRemove:
+		return d.tryRemoveReaction(e.MessageReaction)
+	case *discordgo.MessageReactionRemoveAll:
+		return d.tryRemoveReactionsBulk(e.MessageReaction)
+	default:
+		return fmt.Errorf("unknown event type %T", e)
+	}
+}
+
+// eventKeys returns IDs of messages event changes, and channel ID for bulk deletes. Events sharing any of
+// the keys are applied in order they were received, others in parallel. Snowflakes are unique across
+// resource types, so messages and channels never share a key.
+func eventKeys(e interface{}) []string {
+	switch e := e.(type) {
+	case *discordgo.MessageCreate:
+		return []string{e.ID}
+	case *discordgo.MessageUpdate:
+		return []string{e.ID}
+	case *discordgo.MessageDelete:
+		return []string{e.ID}
+	case *discordgo.MessageDeleteBulk:
+		return append([]string{e.ChannelID}, e.Messages...)
+	case *discordgo.MessageReactionAdd:
+		return []string{e.MessageID}
+	case *discordgo.MessageReactionRemove:
+		return []string{e.MessageID}
+	case *discordgo.MessageReactionRemoveAll:
+		return []string{e.MessageID}
+	default:
+		return nil
+	}
+}
+
+// pendingEvent is a queued event read from the queue, which is being applied.
+type pendingEvent struct {
+	record *journal.Record
+	done   bool
+}
+
+// pendingEvents keeps queued events being applied in order they were read, so that the queue is
+// acknowledged only up to the first event not applied yet.
+type pendingEvents struct {
+	mu     sync.Mutex
+	events []*pendingEvent
+}
+
+func (p *pendingEvents) add(r *journal.Record) *pendingEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := &pendingEvent{record: r}
+	p.events = append(p.events, e)
+	return e
+}
+
+// complete marks event as applied, acknowledging it in queue along with following applied events unless
+// an earlier event is still being applied.
+func (p *pendingEvents) complete(e *pendingEvent, q *journal.Journal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.done = true
+
+	var last *journal.Record
+	for len(p.events) > 0 && p.events[0].done {
+		last = p.events[0].record
+		p.events[0] = nil
+		p.events = p.events[1:]
+	}
+	if last == nil {
+		return nil
+	}
+	return q.Ack(last)
+}
+
+// runQueueConsumer starts applying queued events, using up to the configured number of workers. Events of
+// the same message are applied in order they were received (see eventKeys.)
+func (d *Discord) runQueueConsumer() {
+	d.workersWg.Add(1)
+	go func() {
+		defer d.workersWg.Done()
+		defer d.events.wait()
+
+		var pending pendingEvents
+		for {
+			r, err := d.queue.Next(d.ctx)
+			if d.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, journal.ErrCorrupt) {
+				d.logger.Errorf("Skipping queued event: %s.", err)
+				continue
+			} else if err != nil {
+				d.logger.Errorf("Failed to read queued event: %s.", err)
+				if sleep(d.ctx, queueMaxDelay) != nil {
+					return
+				}
+				continue
+			}
+
+			pe := pending.add(r)
+			complete := func() {
+				if err := pending.complete(pe, d.queue); err != nil {
+					d.logger.Errorf("Failed to acknowledge queued event: %s.", err)
+				}
+			}
+			e, err := decodeEvent(r.Type, r.Data)
+			if err != nil {
+				d.logger.Errorf("Failed to decode queued %s event: %s.", r.Type, err)
+				complete()
+				continue
+			}
+
+			if !d.events.dispatch(d.ctx, eventKeys(e), func() {
+				defer func() {
+					// Panics in applying the event are handled by consume, this only keeps a failure
+					// elsewhere from crashing the application on every start
+					if p := recover(); p != nil {
+						d.logger.Errorf("Recovered from panic consuming %s event: %v.\n%s", r.Type, p, debug.Stack())
+						complete()
+					}
+				}()
+				if d.consume(r.Type, e) {
+					complete()
+				}
+				// Otherwise stopping, and the event is applied again on the next start
+			}) {
+				return
+			}
+		}
+	}()
+}
+
+// consume applies queued event, retrying with exponential backoff. Attempts made while the database is
+// unavailable are not counted, so that no events are lost during an outage. Events still failing after
+// the configured number of attempts are stored to be retried later (see dead_letter.go.) Returns false if
+// the bot is stopping before the event could be applied.
+func (d *Discord) consume(typ string, e interface{}) bool {
+	delay := queueMinDelay
+	for attempt := 1; ; {
+		err := d.safeApplyEvent(typ, e)
+		switch {
+		case err == nil:
+			return true
+		case d.ctx.Err() != nil:
+			return false
+		case errors.Is(err, errPanicked):
+			// Applying the event again would panic again
+			d.storeFailedEvent(typ, e, attempt, err)
+			return true
+		case errors.Is(err, errPostNotStored):
+			// Most reactions are to messages without images, which are not stored
+			d.logger.Debugf("Skipping %s event: %s.", typ, err)
+			return true
+		}
+
+		if perr := d.storage.Ping(d.ctx); perr != nil {
+			d.logger.Warnf("Storage is unavailable, retrying %s event in %s: %s.", typ, delay, perr)
+		} else if attempt >= d.config.queueMaxAttempts {
+			d.logger.Errorf("Failed to apply %s event after %d attempts: %s.", typ, attempt, err)
+			d.storeFailedEvent(typ, e, attempt, err)
+			return true
+		} else {
+			d.logger.Warnf("Failed to apply %s event (attempt %d of %d), retrying in %s: %s.", typ, attempt, d.config.queueMaxAttempts, delay, err)
+			attempt++
+		}
+
+		if sleep(d.ctx, delay) != nil {
+			return false
+		}
+		if delay *= 2; delay > queueMaxDelay {
+			delay = queueMaxDelay
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// storedPost is a post loaded for reconciliation along with the reactions stored for it, keyed by
+// emojiKey.
+type storedPost struct {
+	post      *model.Post
+	reactions map[string]*model.ReactionCount
+}
+
+// emojiKey identifies emoji by custom emoji ID or, for Unicode emojis, by name.
+func emojiKey(em *model.Emoji) string {
+	if em.DiscordID.Valid {
+		return strconv.FormatInt(em.DiscordID.Int64, 10)
+	}
+	return em.Name
+}
+
+// reconcileChannel compares posts of channel with the specified ID created within the configured
+// reconciliation window with current state of their messages, applying edits, deletions and reaction
+// changes that were missed while the bot was offline. Changes are applied through the same paths as
+// live events, never at once with events of the same message (see reconcilePost.)
+func (d *Discord) reconcileChannel(ID string) {
+	if d.config.reconcileWindow <= 0 {
+		return
+	}
+
+	since := model.TimeSnowflake(time.Now().Add(-d.config.reconcileWindow))
+	posts, err := d.findPostsToReconcile(ID, since)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			d.logger.Errorf("Failed to find posts of channel %s to reconcile: %s.", ID, err)
+		}
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	d.logger.Infof("Reconciling %d posts of channel %s.", len(posts), d.describeID(ID))
+	messages, err := d.fetchMessagesAfter(ID, strconv.FormatUint(posts[0].DiscordID-1, 10))
+	if err != nil {
+		d.logger.Errorf("Failed to fetch messages of channel %s to reconcile: %s.", ID, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, pm := range posts {
+		postID, m := pm.DiscordID, messages[pm.DiscordID]
+		if m != nil && m.GuildID == "" {
+			m.GuildID = d.channelGuild(ID)
+		}
+
+		wg.Add(1)
+		if !d.events.dispatch(d.ctx, []string{strconv.FormatUint(postID, 10)}, func() {
+			defer wg.Done()
+			d.reconcilePost(ID, postID, m)
+		}) {
+			wg.Done()
+			break
+		}
+	}
+	wg.Wait()
+	if d.ctx.Err() == nil {
+		d.logger.Infof("Reconciled channel %s.", d.describeID(ID))
+	}
+}
+
+// reconcilePost applies differences between message m, which is nil if the message was deleted, and post
+// with Discord ID postID stored for it. It runs as a task of the event dispatcher keyed by the message, so
+// the post is loaded and changed without events of the message being applied meanwhile. Edits older than
+// the stored one, applied since m was fetched, are not reverted.
+func (d *Discord) reconcilePost(channelID string, postID model.Snowflake, m *discordgo.Message) {
+	if d.ctx.Err() != nil {
+		return
+	}
+	if m == nil {
+		d.deletePost(&discordgo.Message{ID: strconv.FormatUint(postID, 10), ChannelID: channelID})
+		return
+	}
+
+	sp, err := d.findStoredPost(postID)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			d.logger.Errorf("Failed to find post %s to reconcile: %s.", m.ID, err)
+		}
+		return
+	}
+	if sp == nil {
+		// Deleted since the posts were found
+		return
+	}
+
+	if isEdited(sp.post, m) && !isStaleUpdate(sp.post, &discordgo.MessageUpdate{Message: m}) {
+		d.updatePost(m)
+	}
+	if err := d.reconcileReactions(sp, m); err != nil {
+		d.logger.Errorf("Failed to reconcile reactions of post %s: %s.", m.ID, err)
+	}
+}
+
+func (d *Discord) findPostsToReconcile(ID string, after model.Snowflake) ([]*model.Post, error) {
+	var posts []*model.Post
+	err := d.storage.BeginReadOnly(d.ctx, func(tx pgx.Tx) error {
+		cm := model.WrapChannelID(ID)
+		if err := model.FindChannel(d.ctx, tx, cm); err != nil {
+			return fmt.Errorf("failed to find channel: %w", err)
+		}
+		if cm.ID == 0 {
+			return errors.New("channel is not in database")
+		}
+
+		var err error
+		if posts, err = model.FindChannelPostsAfter(d.ctx, tx, cm, after); err != nil {
+			return fmt.Errorf("failed to find posts: %w", err)
+		}
+		return nil
+	})
+	return posts, err
+}
+
+// findStoredPost loads post with the specified Discord ID along with its reactions. Returns nil if the
+// post is not stored.
+func (d *Discord) findStoredPost(ID model.Snowflake) (*storedPost, error) {
+	var sp *storedPost
+	err := d.storage.BeginReadOnly(d.ctx, func(tx pgx.Tx) error {
+		pm := model.WrapMessageID(strconv.FormatUint(ID, 10))
+		if err := model.FindPost(d.ctx, tx, pm); err != nil {
+			return fmt.Errorf("failed to find post: %w", err)
+		}
+		if pm.ID == 0 {
+			return nil
+		}
+
+		counts, err := model.FindReactionCounts(d.ctx, tx, pm)
+		if err != nil {
+			return fmt.Errorf("failed to find reactions: %w", err)
+		}
+		sp = &storedPost{pm, make(map[string]*model.ReactionCount, len(counts))}
+		for _, rc := range counts {
+			sp.reactions[emojiKey(rc.Emoji)] = rc
+		}
+		return nil
+	})
+	return sp, err
+}
+
+// fetchMessagesAfter fetches all messages of channel with the specified ID sent after message with ID
+// afterID, keyed by their IDs.
+func (d *Discord) fetchMessagesAfter(ID string, afterID string) (map[model.Snowflake]*discordgo.Message, error) {
+	messages := make(map[model.Snowflake]*discordgo.Message)
+	for {
+		var ms []*discordgo.Message
+		if err := d.rest(priorityBackfill, func() (err error) {
+			ms, err = d.session.ChannelMessages(ID, 100, "", afterID, "")
+			return
+		}); err != nil {
+			return nil, err
+		}
+
+		if len(ms) == 0 {
+			return messages, nil
+		}
+
+		for _, m := range ms {
+			messages[model.MustParseSnowflake(m.ID)] = m
+		}
+
+		// Messages are returned newest first
+		afterID = ms[0].ID
+	}
+}
+
+// isEdited checks if message differs from the stored post.
+func isEdited(p *model.Post, m *discordgo.Message) bool {
+	if p.Message != m.Content {
+		return true
+	}
+
+	edited, err := m.EditedTimestamp.Parse()
+	if err != nil {
+		return false
+	}
+	return !p.EditedAt.Valid || !p.EditedAt.Time.Equal(edited)
+}
+
+// reconcileReactions applies differences between reactions of message and the ones stored for the post.
+// Users are only fetched for emojis whose count differs.
+func (d *Discord) reconcileReactions(sp *storedPost, m *discordgo.Message) error {
+	if len(m.Reactions) == 0 {
+		if len(sp.reactions) > 0 {
+			d.removeReactionsBulk(&discordgo.MessageReaction{MessageID: m.ID, ChannelID: m.ChannelID, GuildID: m.GuildID})
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool, len(m.Reactions))
+	for _, mr := range m.Reactions {
+		em := model.WrapDiscordEmoji(mr.Emoji)
+		key := emojiKey(em)
+		seen[key] = true
+		if rc, stored := sp.reactions[key]; stored && rc.Count == uint32(mr.Count) {
+			continue
+		}
+
+		if err := d.reconcileEmojiReactions(sp.post, m, mr.Emoji, em); err != nil {
+			return err
+		}
+	}
+
+	// Emojis nobody reacts with anymore
+	for key, rc := range sp.reactions {
+		if seen[key] {
+			continue
+		}
+
+		if err := d.reconcileEmojiReactions(sp.post, m, modelEmojiToDiscord(rc.Emoji), rc.Emoji); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileEmojiReactions adds and removes reactions with a single emoji, so that users stored for the
+// post match users currently reacting to message.
+func (d *Discord) reconcileEmojiReactions(p *model.Post, m *discordgo.Message, dem *discordgo.Emoji, em *model.Emoji) error {
+	current := make(map[model.Snowflake]bool)
+	if containsReaction(m, em) {
+		users, err := d.fetchReactionUsers(m.ChannelID, m.ID, dem, priorityBackfill)
+		if err != nil {
+			return fmt.Errorf("failed to fetch user reactions: %w", err)
+		}
+		for _, u := range users {
+			current[model.MustParseSnowflake(u.ID)] = true
+		}
+	}
+
+	var stored []model.Snowflake
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		stored, err = model.FindReactionUserIDs(d.ctx, tx, p, em)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to find user reactions: %w", err)
+	}
+
+	reaction := func(userID model.Snowflake) *discordgo.MessageReaction {
+		return &discordgo.MessageReaction{
+			UserID:    strconv.FormatUint(userID, 10),
+			MessageID: m.ID,
+			Emoji:     *dem,
+			ChannelID: m.ChannelID,
+			GuildID:   m.GuildID,
+		}
+	}
+
+	for _, u := range stored {
+		if current[u] {
+			delete(current, u)
+		} else {
+			d.removeReaction(reaction(u))
+		}
+	}
+	for u := range current {
+		d.addReaction(reaction(u))
+	}
+
+	return nil
+}
+
+func containsReaction(m *discordgo.Message, em *model.Emoji) bool {
+	for _, mr := range m.Reactions {
+		if emojiKey(model.WrapDiscordEmoji(mr.Emoji)) == emojiKey(em) {
+			return true
+		}
+	}
+	return false
+}
+
+func modelEmojiToDiscord(em *model.Emoji) *discordgo.Emoji {
+	dem := &discordgo.Emoji{Name: em.Name}
+	if em.DiscordID.Valid {
+		dem.ID = strconv.FormatInt(em.DiscordID.Int64, 10)
+	}
+	return dem
+}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// recovering wraps event handler h, which has to be a func(*discordgo.Session, *discordgo.SomeEvent), so
+// that a panic in it is logged and counted (see HandlerFailures) instead of crashing the application.
+// The returned handler has the same type as h, so discordgo still dispatches only events of its type to it.
+func (d *Discord) recovering(h interface{}) interface{} {
+	v := reflect.ValueOf(h)
+	event := strings.TrimPrefix(v.Type().In(1).String(), "*discordgo.")
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		defer func() {
+			if r := recover(); r != nil {
+				n := d.countHandlerFailure(event)
+				d.logger.Errorf("Recovered from panic in %s handler (%d failures so far): %v.\n%s", event, n, r, debug.Stack())
+			}
+		}()
+		return v.Call(args)
+	}).Interface()
+}
+
+// errPanicked is returned by safeApplyEvent when applying an event panicked.
+var errPanicked = errors.New("panicked")
+
+// safeApplyEvent is applyEvent returning a panic in it as errPanicked error, which is logged and counted
+// as a failure of the event type (see HandlerFailures.)
+func (d *Discord) safeApplyEvent(typ string, e interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			n := d.countHandlerFailure(typ)
+			d.logger.Errorf("Recovered from panic applying %s event (%d failures so far): %v.\n%s", typ, n, r, debug.Stack())
+			err = fmt.Errorf("%w: %v", errPanicked, r)
+		}
+	}()
+	return d.applyEvent(e)
+}
+
+// countHandlerFailure counts a failure of handler of the specified event, returning the number of its
+// failures so far.
+func (d *Discord) countHandlerFailure(event string) uint64 {
+	d.failuresMu.Lock()
+	defer d.failuresMu.Unlock()
+	d.failures[event]++
+	return d.failures[event]
+}
+
+// HandlerFailure is the number of times handler of an event type failed since startup.
+type HandlerFailure struct {
+	Event    string
+	Failures uint64
+}
+
+// HandlerFailures returns the number of failures of every event handler that failed since startup,
+// ordered by event type.
+func (d *Discord) HandlerFailures() []HandlerFailure {
+	d.failuresMu.Lock()
+	defer d.failuresMu.Unlock()
+
+	failures := make([]HandlerFailure, 0, len(d.failures))
+	for e, n := range d.failures {
+		failures = append(failures, HandlerFailure{e, n})
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Event < failures[j].Event })
+	return failures
+}
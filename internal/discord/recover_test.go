@@ -0,0 +1,44 @@
+package discord
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSafeApplyEventRecoversPanic(t *testing.T) {
+	// Without storage, storing the post panics
+	d := newTestDiscord(t, nil, nil)
+	e := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:          "1",
+		ChannelID:   "20",
+		GuildID:     "10",
+		Attachments: []*discordgo.MessageAttachment{{ID: "2", Width: 1, Height: 1}},
+	}}
+
+	err := d.safeApplyEvent("MessageCreate", e)
+	if !errors.Is(err, errPanicked) {
+		t.Fatalf("expected error wrapping errPanicked, got %v", err)
+	}
+	want := []HandlerFailure{{"MessageCreate", 1}}
+	if got := d.HandlerFailures(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected failures %v, got %v", want, got)
+	}
+}
+
+func TestRecoveringCountsPanics(t *testing.T) {
+	d := newTestDiscord(t, nil, nil)
+	h := d.recovering(func(*discordgo.Session, *discordgo.MessageDelete) {
+		panic("handler failed")
+	}).(func(*discordgo.Session, *discordgo.MessageDelete))
+
+	for i := 0; i < 2; i++ {
+		h(nil, &discordgo.MessageDelete{Message: &discordgo.Message{ID: "1"}})
+	}
+	want := []HandlerFailure{{"MessageDelete", 2}}
+	if got := d.HandlerFailures(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected failures %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,271 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	restMaxAttempts    = 5
+	restInitialBackoff = time.Second
+	restMaxBackoff     = time.Minute
+
+	// progressLogInterval is the number of pages after which synchronization progress is logged.
+	progressLogInterval = 10
+)
+
+// priority of a Discord REST call. Calls made while handling live events are served before calls made by
+// channel synchronization.
+type priority int
+
+const (
+	priorityLive priority = iota
+	priorityBackfill
+)
+
+// restGate limits the number of concurrent Discord REST calls, letting waiting live calls through before
+// backfill ones. After a rate limit is hit, backfill calls are paused for the time Discord asked to wait,
+// leaving the remaining budget to live events. Bucket-level waiting itself is done by discordgo.
+type restGate struct {
+	mu          sync.Mutex
+	free        int
+	waiting     [2][]chan struct{} // indexed by priority
+	pausedUntil time.Time
+}
+
+func newRestGate(concurrency int) *restGate {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &restGate{free: concurrency}
+}
+
+func (g *restGate) acquire(ctx context.Context, p priority) error {
+	if p == priorityBackfill {
+		g.mu.Lock()
+		wait := time.Until(g.pausedUntil)
+		g.mu.Unlock()
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+
+	g.mu.Lock()
+	if g.free > 0 && len(g.waiting[priorityLive]) == 0 && (p == priorityLive || len(g.waiting[priorityBackfill]) == 0) {
+		g.free--
+		g.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	g.waiting[p] = append(g.waiting[p], ch)
+	g.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		for i, w := range g.waiting[p] {
+			if w == ch {
+				g.waiting[p] = append(g.waiting[p][:i], g.waiting[p][i+1:]...)
+				g.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		g.mu.Unlock()
+		g.release() // granted concurrently with cancellation
+		return ctx.Err()
+	}
+}
+
+func (g *restGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for p := range g.waiting {
+		if len(g.waiting[p]) > 0 {
+			close(g.waiting[p][0])
+			g.waiting[p] = g.waiting[p][1:]
+			return
+		}
+	}
+	g.free++
+}
+
+// pause pauses backfill calls for the specified duration.
+func (g *restGate) pause(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(g.pausedUntil) {
+		g.pausedUntil = until
+	}
+}
+
+// rest calls Discord REST API with fn, limited by restGate and retried with exponential backoff on rate
+// limits, server errors and network errors.
+func (d *Discord) rest(p priority, fn func() error) error {
+	backoff := restInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err := d.gate.acquire(d.ctx, p); err != nil {
+			return err
+		}
+		err := fn()
+		d.gate.release()
+
+		if err == nil || attempt == restMaxAttempts || !isRetryableRESTError(err) {
+			return err
+		}
+
+		d.logger.Warnf("Discord REST call failed (attempt %d of %d), retrying in %s: %s.", attempt, restMaxAttempts, backoff, err)
+		if err := sleep(d.ctx, backoff); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > restMaxBackoff {
+			backoff = restMaxBackoff
+		}
+	}
+}
+
+func isRetryableRESTError(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		code := restErr.Response.StatusCode
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// onRateLimit is called by discordgo when a REST call hits a rate limit.
+func (d *Discord) onRateLimit(_ *discordgo.Session, e *discordgo.RateLimit) {
+	d.logger.Warnf("Hit Discord rate limit on %s, pausing synchronization for %s.", e.URL, e.RetryAfter)
+	d.gate.pause(e.RetryAfter)
+}
+
+// Sync workers
+
+// runSyncWorkers starts the configured number of workers synchronizing queued channels.
+func (d *Discord) runSyncWorkers() {
+	for i := 0; i < d.config.syncWorkers; i++ {
+		d.workersWg.Add(1)
+		go func() {
+			defer d.workersWg.Done()
+			for {
+				select {
+				case <-d.ctx.Done():
+					return
+				case c := <-d.syncQueue:
+					d.syncChannel(strconv.FormatUint(c, 10))
+					d.finishChannelSync(c)
+				}
+			}
+		}()
+	}
+}
+
+// queueChannelSync queues synchronization of channel with the specified ID, unless it is already queued
+// or being synchronized.
+func (d *Discord) queueChannelSync(c uint64) {
+	if !d.startChannelSync(c) {
+		d.logger.Debugf("Channel %s is already being synchronized.", d.describe(c))
+		return
+	}
+
+	d.updateProgress(c, func(p *SyncProgress) {
+		*p = SyncProgress{ChannelID: c, State: SyncQueued}
+	})
+	select {
+	case <-d.ctx.Done():
+	case d.syncQueue <- c:
+	}
+}
+
+// Progress
+
+// States of channel synchronization.
+const (
+	SyncQueued   = "queued"
+	SyncRunning  = "running"
+	SyncFinished = "finished"
+	SyncFailed   = "failed"
+	SyncStopped  = "stopped"
+)
+
+// SyncProgress is progress of synchronization of a single channel.
+type SyncProgress struct {
+	ChannelID uint64
+	State     string
+	Direction string
+	Pages     uint64
+	Messages  uint64
+	Cursor    string
+	Error     string
+	UpdatedAt time.Time
+}
+
+// SyncProgress returns progress of synchronization of every channel that was synchronized since
+// startup, ordered by channel ID.
+func (d *Discord) SyncProgress() []SyncProgress {
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+
+	progress := make([]SyncProgress, 0, len(d.progress))
+	for _, p := range d.progress {
+		progress = append(progress, *p)
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].ChannelID < progress[j].ChannelID })
+	return progress
+}
+
+func (d *Discord) updateProgress(c uint64, update func(p *SyncProgress)) {
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+
+	p, ok := d.progress[c]
+	if !ok {
+		p = &SyncProgress{ChannelID: c}
+		d.progress[c] = p
+	}
+	update(p)
+	p.UpdatedAt = time.Now()
+}
+
+// reportPage records a synchronized page of messages, logging progress every few pages.
+func (d *Discord) reportPage(c uint64, messages int, cursor string) {
+	var p SyncProgress
+	d.updateProgress(c, func(sp *SyncProgress) {
+		sp.Pages++
+		sp.Messages += uint64(messages)
+		sp.Cursor = cursor
+		p = *sp
+	})
+
+	if p.Pages%progressLogInterval == 0 {
+		d.logger.Infof("Synchronizing channel %s %s: %d messages in %d pages so far, at message %s.", d.describe(c), p.Direction, p.Messages, p.Pages, p.Cursor)
+	}
+}
@@ -0,0 +1,31 @@
+package discord
+
+import "github.com/bwmarrin/discordgo"
+
+// Session is the part of Discord API used by the bot: the gateway dispatching events to handlers added
+// with AddHandler, and REST calls. It is implemented by *discordgo.Session, and by fake.Discord, which
+// keeps Discord in memory so that the bot can run without connection to Discord.
+type Session interface {
+	AddHandler(handler interface{}) func()
+	Open() error
+	Close() error
+
+	Channel(channelID string) (*discordgo.Channel, error)
+	GuildChannels(guildID string) ([]*discordgo.Channel, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string) ([]*discordgo.Message, error)
+	ChannelMessage(channelID, messageID string) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+	MessageReactions(channelID, messageID, emojiID string, limit int, beforeID, afterID string) ([]*discordgo.User, error)
+	UserChannelPermissions(userID, channelID string) (int64, error)
+}
+
+var _ Session = (*discordgo.Session)(nil)
+
+// state returns state cache of the session, which is nil for sessions other than discordgo ones. Lookups
+// in a nil state fail as if nothing was cached.
+func (d *Discord) state() *discordgo.State {
+	if s, ok := d.session.(*discordgo.Session); ok {
+		return s.State
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package discord
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
@@ -11,35 +12,62 @@ import (
 	"pkg.mon.icu/monicu/internal/storage/model"
 )
 
+// errPostNotStored is returned when changing reactions of a message that is not stored, such as one
+// without images.
+var errPostNotStored = errors.New("post is not in database")
+
 // Guilds/channels
 
 // createChannelsAndGuilds populates database with entries for guilds and channels that are
-// defined in config.
+// being tracked.
 func (d *Discord) createChannelsAndGuilds() {
-	for _, g := range d.config.guilds.Values() {
+	for _, g := range d.guilds.Values() {
 		gm := model.WrapGuildID(strconv.FormatUint(g, 10))
 		if err := d.createGuild(gm); err != nil {
 			d.logger.Errorf("Failed to create guild: %s.", err)
 			return
 		}
+		if sg, err := d.state().Guild(strconv.FormatUint(g, 10)); err == nil {
+			d.storeGuild(sg)
+		}
 
-		c, err := d.session.GuildChannels(strconv.FormatUint(g, 10))
-		if err != nil {
+		var c []*discordgo.Channel
+		if err := d.rest(priorityLive, func() (err error) {
+			c, err = d.session.GuildChannels(strconv.FormatUint(g, 10))
+			return
+		}); err != nil {
 			d.logger.Errorf("Failed to retrieve guild channels: %s.", err)
 			return
 		}
 		for _, ch := range c {
-			if d.config.chans.Contains(model.MustParseSnowflake(ch.ID)) {
+			if d.chans.Contains(model.MustParseSnowflake(ch.ID)) {
 				cm := model.WrapChannelID(ch.ID)
 				cm.GuildID = gm.ID
 				if err := d.createChannel(cm); err != nil {
 					d.logger.Errorf("Failed to create channel: %s.", err)
+					continue
 				}
+				d.storeChannel(ch)
 			}
 		}
 	}
 }
 
+// createChannelAndGuild populates database with entries for channel with the specified ID and its guild.
+func (d *Discord) createChannelAndGuild(chanID, guildID uint64) error {
+	gm := model.WrapGuildID(strconv.FormatUint(guildID, 10))
+	if err := d.createGuild(gm); err != nil {
+		return fmt.Errorf("failed to create guild: %w", err)
+	}
+
+	cm := model.WrapChannelID(strconv.FormatUint(chanID, 10))
+	cm.GuildID = gm.ID
+	if err := d.createChannel(cm); err != nil {
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+	return nil
+}
+
 // createGuild creates (or finds, effectively creating one if it did not exist) guild entry in database
 // for the specified guild model.
 func (d *Discord) createGuild(gm *model.Guild) error {
@@ -58,67 +86,318 @@ func (d *Discord) createChannel(cm *model.Channel) error {
 
 // Channel sync
 
-// isSyncRequired checks if channel with the specified ID has no posts and requires initial synchronization.
-func (d *Discord) isSyncRequired(ID string) (bool, error) {
+// findChannel finds channel with the specified ID in database.
+func (d *Discord) findChannel(tx pgx.Tx, ID string) (*model.Channel, error) {
+	cm := model.WrapChannelID(ID)
+	if err := model.FindChannel(d.ctx, tx, cm); err != nil {
+		return nil, fmt.Errorf("failed to find channel: %w", err)
+	}
+	if cm.ID == 0 {
+		return nil, errors.New("channel is not in database")
+	}
+	return cm, nil
+}
+
+// loadChannelSync loads persisted synchronization state of channel with the specified ID, as well as
+// whether the channel has no posts.
+func (d *Discord) loadChannelSync(ID string) (*model.ChannelSync, bool, error) {
+	var cs *model.ChannelSync
 	var empty bool
-	return empty, d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
-		cm := model.WrapChannelID(ID)
-		if err := model.FindChannel(d.ctx, tx, cm); err != nil && !errors.Is(err, context.Canceled) {
-			return fmt.Errorf("failed to find or create channel: %w", err)
+	return cs, empty, d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		cm, err := d.findChannel(tx, ID)
+		if err != nil {
+			return err
 		}
-		if cm.ID == 0 {
-			return errors.New("channel is not in database")
+
+		cs = model.NewChannelSync(cm.ID, "")
+		if err := model.FindChannelSync(d.ctx, tx, cs); err != nil {
+			return fmt.Errorf("failed to find channel synchronization state: %w", err)
 		}
 
-		var err error
-		if empty, err = model.IsChannelEmpty(d.ctx, tx, cm); err != nil && !errors.Is(err, context.Canceled) {
+		if empty, err = model.IsChannelEmpty(d.ctx, tx, cm); err != nil {
 			return fmt.Errorf("failed to check if channel is empty: %w", err)
 		}
 		return nil
 	})
 }
 
-// syncChannel performs initial synchronization of channel with the specified ID.
+// saveChannelSync persists synchronization state using the specified model function.
+func (d *Discord) saveChannelSync(cs *model.ChannelSync, save func(context.Context, pgx.Tx, *model.ChannelSync) (bool, error)) {
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		_, err := save(d.ctx, tx, cs)
+		return err
+	}); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to save synchronization state of channel %d: %s.", cs.ChannelID, err)
+	}
+}
+
+// startChannelSyncState persists start of synchronization in the specified direction from cursor.
+func (d *Discord) startChannelSyncState(cs *model.ChannelSync, direction string, cursor string) error {
+	cs.Direction, cs.Cursor = direction, model.NullableSnowflake{}
+	if cursor != "" {
+		cs.Cursor = model.NullableSnowflake{Int64: int64(model.MustParseSnowflake(cursor)), Valid: true}
+	}
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		return model.StartChannelSync(d.ctx, tx, cs)
+	})
+}
+
+// failChannelSync persists error that interrupted synchronization of channel with the specified ID, so it
+// is resumed later.
+func (d *Discord) failChannelSync(ID string, cs *model.ChannelSync, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	d.logger.Errorf("Failed to synchronize channel %s: %s.", d.describeID(ID), err)
+	cs.Error = sql.NullString{String: err.Error(), Valid: true}
+	d.saveChannelSync(cs, model.UpdateChannelSync)
+	d.updateProgress(model.MustParseSnowflake(ID), func(p *SyncProgress) {
+		p.State, p.Error = SyncFailed, err.Error()
+	})
+}
+
+// advanceChannelSync persists ID of the last processed message.
+func (d *Discord) advanceChannelSync(cs *model.ChannelSync, cursor string) {
+	cs.Cursor = model.NullableSnowflake{Int64: int64(model.MustParseSnowflake(cursor)), Valid: true}
+	d.saveChannelSync(cs, model.UpdateChannelSync)
+}
+
+func cursorID(cs *model.ChannelSync) string {
+	if !cs.Cursor.Valid {
+		return ""
+	}
+	return strconv.FormatInt(cs.Cursor.Int64, 10)
+}
+
+// syncChannel synchronizes channel with the specified ID, resuming from its persisted state.
+//
+// Channels that have never been synchronized and have no posts, as well as channels whose initial
+// synchronization was interrupted, are first backfilled from the newest message to the oldest (see
+// backfillChannel.) Then channels catch up with messages sent after their latest post or forward cursor
+// (see catchUpChannel) and are reconciled (see reconcileChannel.)
 func (d *Discord) syncChannel(ID string) {
-	var beforeID string
+	cs, empty, err := d.loadChannelSync(ID)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			d.logger.Errorf("Failed to load synchronization state of channel %s: %s.", ID, err)
+		}
+		return
+	}
+
+	backfillInterrupted := cs.ID != 0 && cs.Direction == model.SyncBackward && !cs.FinishedAt.Valid
+	if cs.ID == 0 && empty || backfillInterrupted {
+		if !backfillInterrupted {
+			if err := d.startChannelSyncState(cs, model.SyncBackward, ""); err != nil {
+				d.failChannelSync(ID, cs, fmt.Errorf("failed to start synchronization: %w", err))
+				return
+			}
+		} else {
+			d.logger.Infof("Resuming synchronization of channel %s before message %s.", d.describeID(ID), cursorID(cs))
+		}
+
+		if !d.backfillChannel(ID, cs) {
+			return
+		}
+	}
+
+	afterID, err := d.latestPostID(ID)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			d.logger.Errorf("Failed to find latest post of channel %s: %s.", ID, err)
+		}
+		return
+	}
+	// Interrupted catch-up is resumed from its cursor even if newer posts were stored since, as messages
+	// after the cursor may be missing
+	catchUpInterrupted := cs.ID != 0 && cs.Direction == model.SyncForward && !cs.FinishedAt.Valid
+	if cs.Direction == model.SyncForward && cs.Cursor.Valid && (catchUpInterrupted || uint64(cs.Cursor.Int64) > parseCursor(afterID)) {
+		afterID = cursorID(cs)
+	}
+
+	if err := d.startChannelSyncState(cs, model.SyncForward, afterID); err != nil {
+		d.failChannelSync(ID, cs, fmt.Errorf("failed to start synchronization: %w", err))
+		return
+	}
+	if !d.catchUpChannel(ID, cs) {
+		return
+	}
+
+	d.reconcileChannel(ID)
+	d.updateProgress(model.MustParseSnowflake(ID), func(p *SyncProgress) {
+		p.State = SyncFinished
+	})
+}
+
+func parseCursor(ID string) uint64 {
+	if ID == "" {
+		return 0
+	}
+	return model.MustParseSnowflake(ID)
+}
+
+// backfillChannel performs initial synchronization of channel with the specified ID, walking its history
+// backward from cs.Cursor (or the newest message) and persisting the cursor after every page. Returns
+// true if the whole history was synchronized.
+func (d *Discord) backfillChannel(ID string, cs *model.ChannelSync) bool {
+	c := model.MustParseSnowflake(ID)
+	d.updateProgress(c, func(p *SyncProgress) {
+		p.State, p.Direction = SyncRunning, model.SyncBackward
+	})
+
+	beforeID := cursorID(cs)
 	for {
-		ms, err := d.session.ChannelMessages(ID, 100, beforeID, "", "")
-		if err != nil {
-			d.logger.Errorf("Failed to fetch messages: %s.", err)
+		if d.stoppedTracking(c) {
+			return false
+		}
+
+		var ms []*discordgo.Message
+		if err := d.rest(priorityBackfill, func() (err error) {
+			ms, err = d.session.ChannelMessages(ID, 100, beforeID, "", "")
 			return
+		}); err != nil {
+			d.failChannelSync(ID, cs, fmt.Errorf("failed to fetch messages: %w", err))
+			return false
 		}
 
 		if len(ms) == 0 {
 			break
 		}
 
+		// Cursor is only advanced past the page once all of its messages are stored, so a message that
+		// failed is not skipped when resuming
 		for _, m := range ms {
 			if d.ctx.Err() != nil {
-				return
+				return false
 			}
 
-			d.createPost(m)
+			if err := d.tryCreatePost(m, priorityBackfill); err != nil {
+				d.failChannelSync(ID, cs, fmt.Errorf("failed to create post %s: %w", m.ID, err))
+				return false
+			}
 		}
 
 		beforeID = ms[len(ms)-1].ID
+		d.advanceChannelSync(cs, beforeID)
+		d.reportPage(c, len(ms), beforeID)
 	}
+
+	d.saveChannelSync(cs, model.FinishChannelSync)
+	d.logger.Infof("Synchronized channel %s.", d.describeID(ID))
+	return true
 }
 
-// syncChannel attempts synchronization of all channels defined in config in parallel skipping channels
-// that do not require initial synchronization.
-func (d *Discord) syncChannels() {
-	for _, c := range d.config.chans.Values() {
-		cID := strconv.FormatUint(c, 10)
+// latestPostID returns Discord ID of the most recent post stored for channel with the specified ID.
+func (d *Discord) latestPostID(ID string) (string, error) {
+	var latestID string
+	return latestID, d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		cm, err := d.findChannel(tx, ID)
+		if err != nil {
+			return err
+		}
 
-		sync, err := d.isSyncRequired(cID)
-		if err != nil && !errors.Is(err, context.Canceled) {
-			d.logger.Errorf("Failed to check if channel %d should be synchronized: %s.", c, err)
-			continue
+		pm := &model.Post{}
+		if err := model.FindLatestPost(d.ctx, tx, cm, pm); err != nil {
+			return fmt.Errorf("failed to find latest post: %w", err)
+		}
+		if pm.ID != 0 {
+			latestID = strconv.FormatUint(pm.DiscordID, 10)
+		}
+		return nil
+	})
+}
+
+// catchUpChannel creates posts from messages of channel with the specified ID that were sent after
+// message cs.Cursor, such as ones sent while the bot was offline, persisting the cursor after every
+// page. Messages are processed oldest first. Returns true if channel caught up with the newest message.
+func (d *Discord) catchUpChannel(ID string, cs *model.ChannelSync) bool {
+	c := model.MustParseSnowflake(ID)
+	d.updateProgress(c, func(p *SyncProgress) {
+		p.State, p.Direction = SyncRunning, model.SyncForward
+	})
+
+	afterID := cursorID(cs)
+	d.logger.Infof("Catching up channel %s after message %s.", d.describeID(ID), afterID)
+	for {
+		if d.stoppedTracking(c) {
+			return false
 		}
 
-		if sync {
-			go d.syncChannel(cID)
+		var ms []*discordgo.Message
+		if err := d.rest(priorityBackfill, func() (err error) {
+			ms, err = d.session.ChannelMessages(ID, 100, "", afterID, "")
+			return
+		}); err != nil {
+			d.failChannelSync(ID, cs, fmt.Errorf("failed to fetch messages: %w", err))
+			return false
+		}
+
+		if len(ms) == 0 {
+			break
 		}
+
+		// Messages are returned newest first, cursor is only advanced past the page once all of them
+		// are stored, so a message that failed is not skipped when resuming
+		for i := len(ms) - 1; i >= 0; i-- {
+			if d.ctx.Err() != nil {
+				return false
+			}
+
+			if err := d.tryCreatePost(ms[i], priorityBackfill); err != nil {
+				d.failChannelSync(ID, cs, fmt.Errorf("failed to create post %s: %w", ms[i].ID, err))
+				return false
+			}
+		}
+
+		afterID = ms[0].ID
+		d.advanceChannelSync(cs, afterID)
+		d.reportPage(c, len(ms), afterID)
+	}
+
+	d.saveChannelSync(cs, model.FinishChannelSync)
+	d.logger.Infof("Caught up channel %s.", d.describeID(ID))
+	return true
+}
+
+// startChannelSync marks channel with the specified ID as being synchronized, returning false if it
+// already is.
+func (d *Discord) startChannelSync(ID uint64) bool {
+	d.syncingMu.Lock()
+	defer d.syncingMu.Unlock()
+
+	if _, syncing := d.syncing[ID]; syncing {
+		return false
+	}
+	d.syncing[ID] = struct{}{}
+	return true
+}
+
+func (d *Discord) finishChannelSync(ID uint64) {
+	d.syncingMu.Lock()
+	defer d.syncingMu.Unlock()
+
+	delete(d.syncing, ID)
+}
+
+// stoppedTracking checks if channel with the specified ID stopped being tracked while being synchronized,
+// in which case synchronization is stopped and resumed from the checkpoint if it is tracked again.
+func (d *Discord) stoppedTracking(c uint64) bool {
+	if d.chans.Contains(c) {
+		return false
+	}
+
+	d.logger.Infof("Channel %s is no longer tracked, stopping synchronization.", d.describe(c))
+	d.updateProgress(c, func(p *SyncProgress) {
+		p.State = SyncStopped
+	})
+	return true
+}
+
+// syncChannels queues synchronization of all channels being tracked (see syncChannel), which are
+// then synchronized by a bounded number of workers (see runSyncWorkers.)
+func (d *Discord) syncChannels() {
+	for _, c := range d.chans.Values() {
+		d.queueChannelSync(c)
 	}
 }
 
@@ -171,18 +450,25 @@ func (d *Discord) createPostImages(tx pgx.Tx, m *discordgo.Message, pm *model.Po
 	return nil
 }
 
-// createPost creates a post from Discord message.
-func (d *Discord) createPost(m *discordgo.Message) {
+// createPost creates a post from Discord message, making REST calls with the specified priority.
+func (d *Discord) createPost(m *discordgo.Message, p priority) {
+	if err := d.tryCreatePost(m, p); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to create post: %s.", err)
+	}
+}
+
+// tryCreatePost is createPost returning the error instead of logging it.
+func (d *Discord) tryCreatePost(m *discordgo.Message, p priority) error {
 	if !d.isValidPost(m) {
 		d.logger.Debugf("Skipping message %s.", m.ID)
-		return
+		return nil
 	}
 	d.logger.Infof("Creating post %s.", m.ID)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
 		if m.GuildID == "" {
 			// In cases such as channel synchronization message will likely lack GuildID
 			// So we pull it from the cache
-			m.GuildID = strconv.FormatUint(d.channelGuildRelations[model.MustParseSnowflake(m.ChannelID)], 10)
+			m.GuildID = d.channelGuild(m.ChannelID)
 		}
 
 		gm := model.WrapGuildID(m.GuildID) // guild model
@@ -206,8 +492,25 @@ func (d *Discord) createPost(m *discordgo.Message) {
 		if err := model.FindOrCreateUser(d.ctx, tx, um); err != nil {
 			return fmt.Errorf("failed to find or create user: %w", err)
 		}
+		if err := d.updateUserProfile(tx, um, m.Author); err != nil {
+			return err
+		}
+		if m.Member != nil {
+			if err := d.updateMemberNickname(tx, um, gm, m.Member.Nick); err != nil {
+				return err
+			}
+		}
 
 		pm := model.WrapDiscordMessage(m) // post model
+		if err := model.FindPost(d.ctx, tx, pm); err != nil {
+			return fmt.Errorf("failed to find post: %w", err)
+		}
+		if pm.ID != 0 {
+			// Live events and catch-up synchronization may race for the same message
+			d.logger.Debugf("Post %s already exists, skipping.", m.ID)
+			return nil
+		}
+
 		pm.ChannelID, pm.UserID = cm.ID, um.ID
 		if err := model.CreatePost(d.ctx, tx, pm); err != nil {
 			return fmt.Errorf("failed to find or create channel: %w", err)
@@ -257,61 +560,86 @@ func (d *Discord) createPost(m *discordgo.Message) {
 				return fmt.Errorf("failed to create reaction: %w", err)
 			}
 
-			var afterID string
-			for {
-				ur, err := d.session.MessageReactions(m.ChannelID, m.ID, mr.Emoji.APIName(), 100, "", afterID)
-				if err != nil {
-					return fmt.Errorf("failed to fetch user reactions: %w", err)
-				}
+			ur, err := d.fetchReactionUsers(m.ChannelID, m.ID, mr.Emoji, p)
+			if err != nil {
+				return fmt.Errorf("failed to fetch user reactions: %w", err)
+			}
 
-				if len(ur) == 0 {
-					break
+			for _, u := range ur {
+				rum := model.WrapUserID(u.ID) // reacted user model
+				if err := model.FindOrCreateUser(d.ctx, tx, rum); err != nil {
+					return fmt.Errorf("failed to find or create user for Discord ID %s: %w", u.ID, err)
 				}
-
-				for _, u := range ur {
-					rum := model.WrapUserID(u.ID) // reacted user model
-					if err := model.FindOrCreateUser(d.ctx, tx, rum); err != nil {
-						return fmt.Errorf("failed to find or create user for Discord ID %s: %w", u.ID, err)
-					}
-
-					urm := model.NewUserReaction() // user reaction model
-					urm.ReactionID, urm.UserID = rm.ID, rum.ID
-					if err := model.CreateUserReaction(d.ctx, tx, urm); err != nil {
-						return fmt.Errorf("failed to create user reaction: %w", err)
-					}
+				if err := d.updateUserProfile(tx, rum, u); err != nil {
+					return err
 				}
 
-				afterID = ur[len(ur)-1].ID
+				urm := model.NewUserReaction() // user reaction model
+				urm.ReactionID, urm.UserID = rm.ID, rum.ID
+				if err := model.CreateUserReaction(d.ctx, tx, urm); err != nil {
+					return fmt.Errorf("failed to create user reaction: %w", err)
+				}
 			}
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to create post: %s.", err)
+	})
+}
+
+// fetchReactionUsers fetches all users that reacted to message with the specified emoji.
+func (d *Discord) fetchReactionUsers(channelID, messageID string, em *discordgo.Emoji, p priority) ([]*discordgo.User, error) {
+	var users []*discordgo.User
+	var afterID string
+	for {
+		var ur []*discordgo.User
+		if err := d.rest(p, func() (err error) {
+			ur, err = d.session.MessageReactions(channelID, messageID, em.APIName(), 100, "", afterID)
+			return
+		}); err != nil {
+			return nil, err
+		}
+
+		if len(ur) == 0 {
+			return users, nil
+		}
+
+		users = append(users, ur...)
+		afterID = ur[len(ur)-1].ID
 	}
 }
 
 // updatePost updates a post (or creates one if an attachment- and embed-less message contained a link
 // and was updated automatically server-side with attachment/embed) from Discord message.
 func (d *Discord) updatePost(m *discordgo.Message) {
+	if err := d.tryUpdatePost(m); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to update post: %s.", err)
+	}
+}
+
+// tryUpdatePost is updatePost returning the error instead of logging it.
+func (d *Discord) tryUpdatePost(m *discordgo.Message) error {
 	d.logger.Infof("Updating post %s.", m.ID)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
-		pm := model.WrapDiscordMessage(m)
-		if err := model.FindPost(d.ctx, tx, pm); err != nil {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		// Stored post is found separately, so that it does not overwrite message content and timestamps
+		pm, sm := model.WrapDiscordMessage(m), model.WrapMessageID(m.ID)
+		if err := model.FindPost(d.ctx, tx, sm); err != nil {
 			return fmt.Errorf("failed to find post: %w", err)
 		}
+		pm.ID = sm.ID
 		if pm.ID == 0 {
-			if om, err := d.session.ChannelMessage(m.ChannelID, m.ID); err != nil {
+			var om *discordgo.Message
+			if err := d.rest(priorityLive, func() (err error) {
+				om, err = d.session.ChannelMessage(m.ChannelID, m.ID)
+				return
+			}); err != nil {
 				return fmt.Errorf("failed to fetch message: %w", err)
 			} else {
 				m.Author = om.Author
-				d.createPost(m)
-				return nil
+				return d.tryCreatePost(m, priorityLive)
 			}
 		}
 		if len(m.Attachments) == 0 && len(m.Embeds) == 0 {
-			d.deletePost(m)
-			return nil
+			return d.tryDeletePost(m)
 		}
 
 		if _, err := model.DeletePostImages(d.ctx, tx, pm); err != nil {
@@ -325,46 +653,48 @@ func (d *Discord) updatePost(m *discordgo.Message) {
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to update post: %s.", err)
-	}
+	})
 }
 
 // deletePost deletes a post from Discord message.
 func (d *Discord) deletePost(m *discordgo.Message) {
+	if err := d.tryDeletePost(m); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to delete post: %s.", err)
+	}
+}
+
+// tryDeletePost is deletePost returning the error instead of logging it.
+func (d *Discord) tryDeletePost(m *discordgo.Message) error {
 	d.logger.Infof("Deleting post %s.", m.ID)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
 		pm := model.WrapDiscordMessage(m)
 		if _, err := model.DeletePost(d.ctx, tx, pm); err != nil {
 			return fmt.Errorf("failed to find post: %w", err)
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to delete post: %s.", err)
-	}
-}
-
-// deletePostsBulk deletes a number of posts from array of Discord IDs.
-func (d *Discord) deletePostsBulk(messages []string) {
-	d.logger.Debugf("Bulk-deleting posts %s-%s.", messages[0], messages[len(messages)-1])
-	for _, m := range messages {
-		d.deletePost(&discordgo.Message{ID: m})
-	}
+	})
 }
 
 // Reactions
 
 // addReaction adds reaction to post loaded from the database for the message that is tied to the specified reaction.
 func (d *Discord) addReaction(r *discordgo.MessageReaction) {
+	if err := d.tryAddReaction(r); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to add reaction: %s.", err)
+	}
+}
+
+// tryAddReaction is addReaction returning the error instead of logging it.
+func (d *Discord) tryAddReaction(r *discordgo.MessageReaction) error {
 	d.logger.Infof("Creating reaction to post %s from user %s with emoji %s.", r.MessageID, r.UserID, r.Emoji.Name)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
 		pm := model.WrapMessageID(r.MessageID)
 		if err := model.FindPost(d.ctx, tx, pm); err != nil {
 			return fmt.Errorf("failed to find post: %w", err)
 		}
 		if pm.ID == 0 {
-			return errors.New("post is not in database")
+			return errPostNotStored
 		}
 
 		em := model.WrapDiscordEmoji(&r.Emoji)
@@ -390,21 +720,26 @@ func (d *Discord) addReaction(r *discordgo.MessageReaction) {
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to add reaction: %s.", err)
-	}
+	})
 }
 
 // removeReaction removes reaction from post loaded from the database for the message that is tied to the specified reaction.
 func (d *Discord) removeReaction(r *discordgo.MessageReaction) {
+	if err := d.tryRemoveReaction(r); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to remove reaction: %s.", err)
+	}
+}
+
+// tryRemoveReaction is removeReaction returning the error instead of logging it.
+func (d *Discord) tryRemoveReaction(r *discordgo.MessageReaction) error {
 	d.logger.Infof("Removing reaction from post %s from user %s with emoji %s.", r.MessageID, r.UserID, r.Emoji.Name)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
 		pm := model.WrapMessageID(r.MessageID)
 		if err := model.FindPost(d.ctx, tx, pm); err != nil {
 			return fmt.Errorf("failed to find post: %w", err)
 		}
 		if pm.ID == 0 {
-			return errors.New("post is not in database")
+			return errPostNotStored
 		}
 
 		em := model.WrapDiscordEmoji(&r.Emoji)
@@ -430,21 +765,26 @@ func (d *Discord) removeReaction(r *discordgo.MessageReaction) {
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to remove reaction: %s.", err)
-	}
+	})
 }
 
 // removeReactionsBulk removes all reactions from post loaded from the database for the message that is tied to the specified reaction.
 func (d *Discord) removeReactionsBulk(r *discordgo.MessageReaction) {
+	if err := d.tryRemoveReactionsBulk(r); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Errorf("Failed to remove reactions: %s.", err)
+	}
+}
+
+// tryRemoveReactionsBulk is removeReactionsBulk returning the error instead of logging it.
+func (d *Discord) tryRemoveReactionsBulk(r *discordgo.MessageReaction) error {
 	d.logger.Infof("Removing all reactions from post %s.", r.MessageID)
-	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+	return d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
 		pm := model.WrapMessageID(r.MessageID)
 		if err := model.FindPost(d.ctx, tx, pm); err != nil {
 			return fmt.Errorf("failed to find post: %w", err)
 		}
 		if pm.ID == 0 {
-			return errors.New("post is not in database")
+			return errPostNotStored
 		}
 
 		if _, err := model.DeleteAllReactions(d.ctx, tx, pm); err != nil {
@@ -452,7 +792,5 @@ func (d *Discord) removeReactionsBulk(r *discordgo.MessageReaction) {
 		}
 
 		return nil
-	}); err != nil && !errors.Is(err, context.Canceled) {
-		d.logger.Errorf("Failed to remove reactions: %s.", err)
-	}
+	})
 }
@@ -0,0 +1,201 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+// trackingRetryDelay is delay before listening for tracking changes again after the connection failed.
+const trackingRetryDelay = 5 * time.Second
+
+// errNotTextChannel is returned when tracking of a channel that cannot contain messages is requested.
+var errNotTextChannel = errors.New("not a guild text channel")
+
+// loadTracking seeds tracked guilds and channels with the ones defined in config, and then loads the
+// tracked set from database. Guilds and channels that stopped being tracked at runtime are not tracked
+// again, even if they are still defined in config.
+func (d *Discord) loadTracking() error {
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		for _, g := range d.config.guilds.Values() {
+			if _, err := model.SeedTrackedGuild(d.ctx, tx, g); err != nil {
+				return fmt.Errorf("failed to seed tracked guild %d: %w", g, err)
+			}
+		}
+		for _, c := range d.config.chans.Values() {
+			if _, err := model.SeedTrackedChannel(d.ctx, tx, c); err != nil {
+				return fmt.Errorf("failed to seed tracked channel %d: %w", c, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	_, err := d.reloadTracking()
+	return err
+}
+
+// reloadTracking replaces tracked guilds and channels with the ones stored in database, returning
+// channels that started being tracked.
+func (d *Discord) reloadTracking() ([]uint64, error) {
+	var guilds []model.Snowflake
+	var chans []*model.TrackedChannel
+	if err := d.storage.BeginReadOnly(d.ctx, func(tx pgx.Tx) error {
+		var err error
+		if guilds, err = model.FindTrackedGuilds(d.ctx, tx); err != nil {
+			return fmt.Errorf("failed to find tracked guilds: %w", err)
+		}
+		if chans, err = model.FindTrackedChannels(d.ctx, tx); err != nil {
+			return fmt.Errorf("failed to find tracked channels: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	chanIDs := make([]uint64, 0, len(chans))
+	for _, c := range chans {
+		chanIDs = append(chanIDs, c.DiscordID)
+		if c.GuildID.Valid {
+			d.setChannelGuild(c.DiscordID, uint64(c.GuildID.Int64))
+		}
+	}
+
+	d.guilds.Replace(guilds)
+	added, removed := d.chans.Replace(chanIDs)
+	for _, c := range removed {
+		d.logger.Infof("Stopped tracking channel %s.", d.describe(c))
+	}
+	return added, nil
+}
+
+// refreshTracking reloads tracked guilds and channels from database, starting tracking of channels
+// added since the last reload.
+func (d *Discord) refreshTracking() {
+	added, err := d.reloadTracking()
+	if err != nil {
+		d.logger.Errorf("Failed to reload tracked channels: %s.", err)
+		return
+	}
+
+	for _, c := range added {
+		go d.startTracking(c)
+	}
+}
+
+// startTracking creates database entries for a newly tracked channel and its guild, tracking the guild
+// as well, and queues initial synchronization of the channel.
+func (d *Discord) startTracking(c uint64) {
+	g, err := d.resolveChannelGuild(c)
+	if err != nil {
+		d.logger.Errorf("Failed to resolve guild of channel %s: %s.", d.describe(c), err)
+		return
+	}
+
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		if err := model.UpdateTrackedChannelGuild(d.ctx, tx, c, g); err != nil {
+			return fmt.Errorf("failed to store guild of channel: %w", err)
+		}
+		if d.guilds.Contains(g) {
+			return nil
+		}
+		if err := model.SetGuildTracked(d.ctx, tx, g, true); err != nil {
+			return fmt.Errorf("failed to track guild: %w", err)
+		}
+		return model.NotifyTrackingChanged(d.ctx, tx)
+	}); err != nil {
+		d.logger.Errorf("Failed to start tracking channel %s: %s.", d.describe(c), err)
+		return
+	}
+	d.guilds.Add(g)
+
+	if err := d.createChannelAndGuild(c, g); err != nil {
+		d.logger.Errorf("Failed to start tracking channel %s: %s.", d.describe(c), err)
+		return
+	}
+	d.storeStateMetadata(c, g)
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		_, err := model.SetChannelArchived(d.ctx, tx, model.WrapChannelID(strconv.FormatUint(c, 10)), false)
+		return err
+	}); err != nil {
+		d.logger.Errorf("Failed to unarchive channel %s: %s.", d.describe(c), err)
+	}
+
+	d.logger.Infof("Started tracking channel %s of guild %s.", d.describe(c), d.describe(g))
+	d.queueChannelSync(c)
+}
+
+// listenTracking starts listening for changes of tracked guilds and channels made by other processes,
+// such as the CLI, reconnecting if the connection to database fails.
+func (d *Discord) listenTracking() {
+	d.workersWg.Add(1)
+	go func() {
+		defer d.workersWg.Done()
+		for {
+			err := d.storage.Listen(d.ctx, model.TrackingChannel, func(string) { d.refreshTracking() })
+			if d.ctx.Err() != nil {
+				return
+			}
+
+			d.logger.Errorf("Failed to listen for tracking changes: %s.", err)
+			if sleep(d.ctx, trackingRetryDelay) != nil {
+				return
+			}
+			// Catch up with changes made while not listening
+			d.refreshTracking()
+		}
+	}()
+}
+
+// TrackChannel starts tracking channel with the specified ID, triggering its initial synchronization.
+func (d *Discord) TrackChannel(ID string) error {
+	var ch *discordgo.Channel
+	if err := d.rest(priorityLive, func() (err error) {
+		ch, err = d.session.Channel(ID)
+		return
+	}); err != nil {
+		return fmt.Errorf("failed to retrieve channel: %w", err)
+	}
+	if ch.GuildID == "" || !isTextChannel(ch) {
+		return errNotTextChannel
+	}
+
+	c, err := model.ParseSnowflake(ch.ID)
+	if err != nil {
+		return err
+	}
+	g, err := model.ParseSnowflake(ch.GuildID)
+	if err != nil {
+		return err
+	}
+	return d.setChannelTracked(&model.TrackedChannel{DiscordID: c, GuildID: model.NullableSnowflake{Int64: int64(g), Valid: true}}, true)
+}
+
+// UntrackChannel stops tracking channel with the specified ID. Posts already stored are kept.
+func (d *Discord) UntrackChannel(ID string) error {
+	c, err := model.ParseSnowflake(ID)
+	if err != nil {
+		return err
+	}
+	return d.setChannelTracked(&model.TrackedChannel{DiscordID: c}, false)
+}
+
+func (d *Discord) setChannelTracked(tc *model.TrackedChannel, tracked bool) error {
+	if err := d.storage.Begin(d.ctx, func(tx pgx.Tx) error {
+		if err := model.SetChannelTracked(d.ctx, tx, tc, tracked); err != nil {
+			return err
+		}
+		return model.NotifyTrackingChanged(d.ctx, tx)
+	}); err != nil {
+		return fmt.Errorf("failed to store tracked channel: %w", err)
+	}
+
+	d.refreshTracking()
+	return nil
+}
@@ -1,13 +1,16 @@
 package discord
 
-// Uint64Set is a simple map-based set of unique uint64 values.
+import "sync"
+
+// Uint64Set is a simple map-based set of unique uint64 values, safe for concurrent use.
 type Uint64Set struct {
+	mu         sync.RWMutex
 	backingMap map[uint64]struct{}
 }
 
 // NewUint64Set creates a new Uint64Set from the specified array of uint64.
 func NewUint64Set(s []uint64) *Uint64Set {
-	set := &Uint64Set{make(map[uint64]struct{}, len(s))}
+	set := &Uint64Set{backingMap: make(map[uint64]struct{}, len(s))}
 	for _, i := range s {
 		set.backingMap[i] = struct{}{}
 	}
@@ -16,12 +19,65 @@ func NewUint64Set(s []uint64) *Uint64Set {
 
 // Contains checks if this Uint64Set contains the specified uint64.
 func (s *Uint64Set) Contains(i uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	_, exists := s.backingMap[i]
 	return exists
 }
 
+// Add adds the specified uint64 to this Uint64Set, returning false if it was already contained.
+func (s *Uint64Set) Add(i uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.backingMap[i]; exists {
+		return false
+	}
+	s.backingMap[i] = struct{}{}
+	return true
+}
+
+// Remove removes the specified uint64 from this Uint64Set, returning false if it was not contained.
+func (s *Uint64Set) Remove(i uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.backingMap[i]; !exists {
+		return false
+	}
+	delete(s.backingMap, i)
+	return true
+}
+
+// Replace replaces contents of this Uint64Set with the specified array of uint64, returning values that
+// were added and removed.
+func (s *Uint64Set) Replace(v []uint64) (added, removed []uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := make(map[uint64]struct{}, len(v))
+	for _, i := range v {
+		m[i] = struct{}{}
+		if _, exists := s.backingMap[i]; !exists {
+			added = append(added, i)
+		}
+	}
+	for i := range s.backingMap {
+		if _, exists := m[i]; !exists {
+			removed = append(removed, i)
+		}
+	}
+
+	s.backingMap = m
+	return added, removed
+}
+
 // Values return values contained by this Uint64Set as array.
 func (s *Uint64Set) Values() []uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	v := make([]uint64, 0, len(s.backingMap))
 	for k := range s.backingMap {
 		v = append(v, k)
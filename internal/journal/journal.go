@@ -0,0 +1,343 @@
+// Package journal implements a durable on-disk queue. Records are appended to segment files as JSON lines
+// and read back in order by a single consumer, which acknowledges records once they are handled. Records
+// not acknowledged before a restart are read again.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// segmentSize is size after which the journal starts appending to a new segment file.
+	segmentSize   = 16 << 20
+	segmentSuffix = ".log"
+	positionFile  = "position"
+)
+
+var (
+	// ErrClosed is returned when appending to a closed journal.
+	ErrClosed = errors.New("journal is closed")
+	// ErrCorrupt is returned by Next for records that cannot be decoded. Such records are skipped.
+	ErrCorrupt = errors.New("corrupt record")
+)
+
+// Record is a single entry of the journal.
+type Record struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+
+	// end is position right after the record, which becomes the read position once it is acknowledged
+	end position
+}
+
+// position is an offset within a segment file.
+type position struct {
+	segment uint64
+	offset  int64
+}
+
+type Journal struct {
+	dir string
+	// segmentSize is the segmentSize constant, which tests can make smaller
+	segmentSize int64
+
+	mu     sync.Mutex
+	w      *os.File
+	wPos   position
+	closed bool
+	notify chan struct{}
+
+	// Fields below are used only by the consumer
+	r       *os.File
+	rb      *bufio.Reader
+	rPos    position
+	partial []byte
+
+	ackMu sync.Mutex
+	acked position
+}
+
+// Open opens journal in the specified directory, creating the directory if it does not exist. A record
+// partially written when the process was terminated is discarded.
+func Open(dir string) (*Journal, error) {
+	return open(dir, segmentSize)
+}
+
+func open(dir string, segmentSize int64) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	segs, err := segments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+	acked, err := readPosition(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position: %w", err)
+	}
+
+	last := acked.segment
+	if len(segs) > 0 && segs[len(segs)-1] > last {
+		last = segs[len(segs)-1]
+	}
+	if last == 0 {
+		last = 1
+	}
+	if len(segs) == 0 || acked.segment < segs[0] {
+		// Position is missing or points to a segment removed since
+		acked = position{segment: last}
+		if len(segs) > 0 {
+			acked.segment = segs[0]
+		}
+	}
+	for _, s := range segs {
+		if s < acked.segment {
+			if err := os.Remove(segmentPath(dir, s)); err != nil {
+				return nil, fmt.Errorf("failed to remove consumed segment: %w", err)
+			}
+		}
+	}
+
+	j := &Journal{dir: dir, segmentSize: segmentSize, notify: make(chan struct{}, 1), acked: acked}
+	if err := j.openWriter(last); err != nil {
+		return nil, err
+	}
+	if err := j.openReader(acked); err != nil {
+		_ = j.w.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// openWriter opens segment for appending, truncating a partially written record at its end.
+func (j *Journal) openWriter(segment uint64) error {
+	f, err := os.OpenFile(segmentPath(j.dir, segment), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+	size := int64(bytes.LastIndexByte(b, '\n') + 1)
+	if size != int64(len(b)) {
+		if err := f.Truncate(size); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to truncate segment: %w", err)
+		}
+	}
+
+	j.w, j.wPos = f, position{segment: segment, offset: size}
+	return nil
+}
+
+// openReader opens segment for reading from the specified position.
+func (j *Journal) openReader(pos position) error {
+	f, err := os.OpenFile(segmentPath(j.dir, pos.segment), os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	if pos.offset, err = f.Seek(pos.offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to seek segment: %w", err)
+	}
+
+	if j.r != nil {
+		_ = j.r.Close()
+	}
+	j.r, j.rb, j.rPos, j.partial = f, bufio.NewReader(f), pos, nil
+	return nil
+}
+
+// Append encodes v as JSON and appends it to the journal as a record of the specified type. The record is
+// synced to disk before Append returns.
+func (j *Journal) Append(typ string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	line, err := json.Marshal(&Record{Type: typ, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+
+	if _, err := j.w.Write(line); err != nil {
+		// Do not leave a partial record in front of the following ones
+		_ = j.w.Truncate(j.wPos.offset)
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	if err := j.w.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	j.wPos.offset += int64(len(line))
+
+	if j.wPos.offset >= j.segmentSize {
+		if err := j.w.Close(); err != nil {
+			return fmt.Errorf("failed to close segment: %w", err)
+		}
+		if err := j.openWriter(j.wPos.segment + 1); err != nil {
+			j.closed = true
+			return err
+		}
+	}
+
+	select {
+	case j.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Next returns the next record, waiting until one is appended or ctx is done. Next must not be called
+// concurrently.
+func (j *Journal) Next(ctx context.Context) (*Record, error) {
+	var rotated bool
+	for {
+		line, err := j.rb.ReadBytes('\n')
+		j.partial = append(j.partial, line...)
+		if err == nil {
+			line, j.partial = j.partial, nil
+			j.rPos.offset += int64(len(line))
+
+			r := &Record{end: j.rPos}
+			if err := json.Unmarshal(line, r); err != nil || r.Type == "" {
+				return nil, fmt.Errorf("%w at offset %d of segment %d", ErrCorrupt, j.rPos.offset-int64(len(line)), j.rPos.segment)
+			}
+			return r, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read segment: %w", err)
+		}
+
+		if rotated {
+			// Writer moved to a newer segment and this one is read to the end
+			if err := j.openReader(position{segment: j.rPos.segment + 1}); err != nil {
+				return nil, err
+			}
+			rotated = false
+			continue
+		}
+
+		j.mu.Lock()
+		rotated = j.wPos.segment > j.rPos.segment
+		j.mu.Unlock()
+		if rotated {
+			// Read once more, as records could have been appended before the writer moved on
+			continue
+		}
+
+		select {
+		case <-j.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack acknowledges that the record was handled and is not to be read again. Records have to be
+// acknowledged in order they are returned by Next.
+func (j *Journal) Ack(r *Record) error {
+	j.ackMu.Lock()
+	defer j.ackMu.Unlock()
+
+	if err := writePosition(j.dir, r.end); err != nil {
+		return fmt.Errorf("failed to write position: %w", err)
+	}
+	for s := j.acked.segment; s < r.end.segment; s++ {
+		if err := os.Remove(segmentPath(j.dir, s)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove consumed segment: %w", err)
+		}
+	}
+
+	j.acked = r.end
+	return nil
+}
+
+// Close closes the journal. Consumer has to be stopped beforehand.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return nil
+	}
+
+	j.closed = true
+	werr := j.w.Close()
+	if err := j.r.Close(); err != nil {
+		return err
+	}
+	return werr
+}
+
+func segmentPath(dir string, segment uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016x%s", segment, segmentSuffix))
+}
+
+// segments returns sorted numbers of segment files in the directory.
+func segments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segs := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		if s, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 16, 64); err == nil {
+			segs = append(segs, s)
+		}
+	}
+
+	sort.Slice(segs, func(a, b int) bool { return segs[a] < segs[b] })
+	return segs, nil
+}
+
+// readPosition reads the acknowledged position, which is zero if it was never written.
+func readPosition(dir string) (position, error) {
+	b, err := os.ReadFile(filepath.Join(dir, positionFile))
+	if os.IsNotExist(err) {
+		return position{}, nil
+	} else if err != nil {
+		return position{}, err
+	}
+
+	var pos position
+	if _, err := fmt.Sscanf(string(b), "%d %d", &pos.segment, &pos.offset); err != nil {
+		return position{}, fmt.Errorf("malformed position %q", b)
+	}
+	return pos, nil
+}
+
+// writePosition replaces the acknowledged position. The position is not synced, as it is fine to handle
+// a few records again after a crash.
+func writePosition(dir string, pos position) error {
+	tmp := filepath.Join(dir, positionFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", pos.segment, pos.offset)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, positionFile))
+}
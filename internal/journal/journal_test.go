@@ -0,0 +1,244 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// next reads the next record, failing the test if none is available within a second.
+func next(t *testing.T, j *Journal) *Record {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	r, err := j.Next(ctx)
+	if err != nil {
+		t.Fatalf("failed to read record: %s", err)
+	}
+	return r
+}
+
+// expectRecord reads the next record and checks that it holds the specified number.
+func expectRecord(t *testing.T, j *Journal, want int) *Record {
+	t.Helper()
+	r := next(t, j)
+
+	var got int
+	if err := json.Unmarshal(r.Data, &got); err != nil {
+		t.Fatalf("failed to decode record: %s", err)
+	}
+	if r.Type != "test" || got != want {
+		t.Fatalf("expected test record %d, got %s record %s", want, r.Type, r.Data)
+	}
+	return r
+}
+
+func expectEmpty(t *testing.T, j *Journal) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if r, err := j.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected no more records, got %v (error %v)", r, err)
+	}
+}
+
+func appendRecords(t *testing.T, j *Journal, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := j.Append("test", i); err != nil {
+			t.Fatalf("failed to append record: %s", err)
+		}
+	}
+}
+
+func listSegments(t *testing.T, dir string) []uint64 {
+	t.Helper()
+	segs, err := segments(dir)
+	if err != nil {
+		t.Fatalf("failed to list segments: %s", err)
+	}
+	return segs
+}
+
+func TestAppendNextAck(t *testing.T) {
+	j, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	defer j.Close()
+
+	appendRecords(t, j, 0, 3)
+	for i := 0; i < 3; i++ {
+		if err := j.Ack(expectRecord(t, j, i)); err != nil {
+			t.Fatalf("failed to acknowledge record: %s", err)
+		}
+	}
+	expectEmpty(t, j)
+
+	appendRecords(t, j, 3, 4)
+	expectRecord(t, j, 3)
+}
+
+func TestReopenReadsUnacknowledged(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+
+	appendRecords(t, j, 0, 3)
+	if err := j.Ack(expectRecord(t, j, 0)); err != nil {
+		t.Fatalf("failed to acknowledge record: %s", err)
+	}
+	expectRecord(t, j, 1)
+	if err := j.Close(); err != nil {
+		t.Fatalf("failed to close journal: %s", err)
+	}
+	if err := j.Append("test", 3); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected append to closed journal to fail with %v, got %v", ErrClosed, err)
+	}
+
+	if j, err = Open(dir); err != nil {
+		t.Fatalf("failed to reopen journal: %s", err)
+	}
+	defer j.Close()
+
+	expectRecord(t, j, 1)
+	expectRecord(t, j, 2)
+	expectEmpty(t, j)
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	j, err := open(dir, 1) // every record gets a segment of its own
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	defer j.Close()
+
+	appendRecords(t, j, 0, 5)
+	if segs := listSegments(t, dir); len(segs) != 6 {
+		t.Fatalf("expected 5 full segments and an empty one, got %v", segs)
+	}
+
+	var last *Record
+	for i := 0; i < 5; i++ {
+		last = expectRecord(t, j, i)
+	}
+	if err := j.Ack(last); err != nil {
+		t.Fatalf("failed to acknowledge record: %s", err)
+	}
+	if segs := listSegments(t, dir); len(segs) != 2 || segs[0] != 5 {
+		t.Errorf("expected consumed segments to be removed, got %v", segs)
+	}
+}
+
+func TestNextAcrossRotationRacingAppend(t *testing.T) {
+	j, err := open(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	defer j.Close()
+
+	const n = 500
+	errs := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := j.Append("test", i); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	for i := 0; i < n; i++ {
+		if err := j.Ack(expectRecord(t, j, i)); err != nil {
+			t.Fatalf("failed to acknowledge record: %s", err)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("failed to append record: %s", err)
+	}
+	expectEmpty(t, j)
+}
+
+func TestOpenPositionOfRemovedSegment(t *testing.T) {
+	dir := t.TempDir()
+	j, err := open(dir, 1)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	appendRecords(t, j, 0, 3)
+	if err := j.Close(); err != nil {
+		t.Fatalf("failed to close journal: %s", err)
+	}
+
+	if err := os.Remove(segmentPath(dir, 1)); err != nil {
+		t.Fatalf("failed to remove segment: %s", err)
+	}
+	if err := writePosition(dir, position{segment: 1, offset: 10}); err != nil {
+		t.Fatalf("failed to write position: %s", err)
+	}
+
+	if j, err = open(dir, 1); err != nil {
+		t.Fatalf("failed to reopen journal: %s", err)
+	}
+	defer j.Close()
+
+	expectRecord(t, j, 1)
+	expectRecord(t, j, 2)
+	expectEmpty(t, j)
+}
+
+func TestCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{`{"type":"test","data":0}`, `{"type":`, `{"data":1}`, `{"type":"test","data":2}`}
+	if err := os.WriteFile(segmentPath(dir, 1), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write segment: %s", err)
+	}
+
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	defer j.Close()
+
+	expectRecord(t, j, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := j.Next(context.Background()); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("expected %v, got %v", ErrCorrupt, err)
+		}
+	}
+	expectRecord(t, j, 2)
+}
+
+func TestTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	complete := `{"type":"test","data":0}` + "\n"
+	if err := os.WriteFile(segmentPath(dir, 1), []byte(complete+`{"type":"test","da`), 0644); err != nil {
+		t.Fatalf("failed to write segment: %s", err)
+	}
+
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %s", err)
+	}
+	defer j.Close()
+
+	if fi, err := os.Stat(segmentPath(dir, 1)); err != nil {
+		t.Fatalf("failed to stat segment: %s", err)
+	} else if fi.Size() != int64(len(complete)) {
+		t.Errorf("expected partial record to be truncated to %d bytes, got %d", len(complete), fi.Size())
+	}
+
+	appendRecords(t, j, 1, 2)
+	expectRecord(t, j, 0)
+	expectRecord(t, j, 1)
+}
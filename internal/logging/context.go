@@ -0,0 +1,22 @@
+// Package logging carries request-scoped loggers through contexts, so that log lines written by different
+// components while serving a single request can be correlated.
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the specified logger.
+func WithLogger(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns logger carried by ctx or nil if there is none.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	l, _ := ctx.Value(loggerKey{}).(*zap.SugaredLogger)
+	return l
+}
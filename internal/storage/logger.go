@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/logging"
+)
+
+// queryLogger logs queries made with contexts carrying a request-scoped logger (see logging package),
+// so that SQL can be correlated with the request it was made for. Queries made without one, such as
+// those of Discord event handlers, are not logged, as their failures are already reported by callers.
+type queryLogger struct{}
+
+func (queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	l := logging.FromContext(ctx)
+	if l == nil {
+		return
+	}
+
+	kv := make([]interface{}, 0, 2*len(data))
+	for k, v := range data {
+		if k == "args" {
+			continue // may contain user data and is rarely useful
+		}
+		kv = append(kv, k, v)
+	}
+
+	switch {
+	case level <= pgx.LogLevelError:
+		l.Warnw("SQL "+msg, kv...)
+	case level <= pgx.LogLevelInfo:
+		l.Debugw("SQL "+msg, kv...)
+	}
+}
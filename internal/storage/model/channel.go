@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v4"
 )
 
@@ -22,3 +23,65 @@ func FindOrCreateChannel(ctx context.Context, tx pgx.Tx, ch *Channel) error {
 func FindChannel(ctx context.Context, tx pgx.Tx, ch *Channel) error {
 	return query(ctx, tx, `select id from channel where discord_id = $1`, []interface{}{ch.DiscordID}, []interface{}{&ch.ID})
 }
+
+// SetChannelArchived marks channel as archived, meaning it was deleted in Discord but its posts are kept,
+// or clears the mark.
+func SetChannelArchived(ctx context.Context, tx pgx.Tx, ch *Channel, archived bool) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update channel set archived_at = case when $2 then coalesce(archived_at, now()) end where discord_id = $1`, []interface{}{ch.DiscordID, archived})
+}
+
+// ArchiveGuildChannels marks all channels of the specified guild as archived.
+func ArchiveGuildChannels(ctx context.Context, tx pgx.Tx, g *Guild) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update channel set archived_at = now() where guild_id = (select id from guild where discord_id = $1) and archived_at is null`, []interface{}{g.DiscordID})
+}
+
+// DeleteChannel deletes channel along with all its posts.
+func DeleteChannel(ctx context.Context, tx pgx.Tx, ch *Channel) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `delete from channel where discord_id = $1`, []interface{}{ch.DiscordID})
+}
+
+// ChannelMetadata is metadata of a channel as set in Discord, where ParentID is Discord ID of its category.
+type ChannelMetadata struct {
+	Name     string
+	Topic    string
+	NSFW     bool
+	Position int
+	ParentID NullableSnowflake
+}
+
+func WrapDiscordChannel(ch *discordgo.Channel) *ChannelMetadata {
+	md := &ChannelMetadata{Name: ch.Name, Topic: ch.Topic, NSFW: ch.NSFW, Position: ch.Position}
+	if p, err := ParseSnowflake(ch.ParentID); err == nil {
+		md.ParentID = NullableSnowflake{Int64: int64(p), Valid: true}
+	}
+	return md
+}
+
+// UpdateChannelMetadata stores metadata of the specified channel, returning false if the channel is not
+// in database.
+func UpdateChannelMetadata(ctx context.Context, tx pgx.Tx, ch *Channel, md *ChannelMetadata) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update channel set name = $2, topic = nullif($3, ''), nsfw = $4, position = $5, parent_discord_id = $6 where discord_id = $1`, []interface{}{ch.DiscordID, md.Name, md.Topic, md.NSFW, md.Position, md.ParentID})
+}
+
+// FindChannelsMetadata finds metadata of channels with the specified Discord IDs, keyed by Discord ID.
+// Channels whose metadata has not been stored yet are omitted.
+func FindChannelsMetadata(ctx context.Context, tx pgx.Tx, channels []Snowflake) (map[Snowflake]*ChannelMetadata, error) {
+	md := make(map[Snowflake]*ChannelMetadata, len(channels))
+	q, err := tx.Query(ctx, `select discord_id, name, coalesce(topic, ''), coalesce(nsfw, false), coalesce(position, 0), parent_discord_id from channel where discord_id = any($1) and name is not null`, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Snowflake
+		m := &ChannelMetadata{}
+		if err := q.Scan(&id, &m.Name, &m.Topic, &m.NSFW, &m.Position, &m.ParentID); err != nil {
+			return nil, err
+		}
+
+		md[id] = m
+	}
+
+	return md, q.Err()
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Directions in which channel history is synchronized.
+const (
+	// SyncBackward walks channel history from the newest message to the oldest, used for initial
+	// synchronization.
+	SyncBackward = "backward"
+	// SyncForward walks channel history from a message to the newest one, used to catch up.
+	SyncForward = "forward"
+)
+
+// ChannelSync is a persistent checkpoint of channel synchronization. Cursor is ID of the last processed
+// message, and Error is set if synchronization was interrupted by an error.
+type ChannelSync struct {
+	IdentifiableEntity
+	ChannelID  Ref
+	Direction  string
+	Cursor     NullableSnowflake
+	StartedAt  time.Time
+	FinishedAt NullableTime
+	Error      sql.NullString
+}
+
+func NewChannelSync(channelID Ref, direction string) *ChannelSync {
+	return &ChannelSync{ChannelID: channelID, Direction: direction}
+}
+
+// FindChannelSync finds synchronization state of channel cs.ChannelID, leaving cs.ID zero if the channel
+// has never been synchronized.
+func FindChannelSync(ctx context.Context, tx pgx.Tx, cs *ChannelSync) error {
+	return query(ctx, tx, `select id, direction, cursor, started_at, finished_at, error from channel_sync where channel_id = $1`, []interface{}{cs.ChannelID}, []interface{}{&cs.ID, &cs.Direction, &cs.Cursor, &cs.StartedAt, &cs.FinishedAt, &cs.Error})
+}
+
+// StartChannelSync records start of synchronization in cs.Direction from cs.Cursor, replacing previous
+// state of the channel.
+func StartChannelSync(ctx context.Context, tx pgx.Tx, cs *ChannelSync) error {
+	return query(ctx, tx, `insert into channel_sync (channel_id, direction, cursor, started_at) values ($1, $2, $3, now()) on conflict (channel_id) do update set direction = excluded.direction, cursor = excluded.cursor, started_at = excluded.started_at, finished_at = null, error = null returning id, started_at`, []interface{}{cs.ChannelID, cs.Direction, cs.Cursor}, []interface{}{&cs.ID, &cs.StartedAt})
+}
+
+// UpdateChannelSync persists cursor and error of an ongoing synchronization.
+func UpdateChannelSync(ctx context.Context, tx pgx.Tx, cs *ChannelSync) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update channel_sync set cursor = $2, error = $3 where id = $1`, []interface{}{cs.ID, cs.Cursor, cs.Error})
+}
+
+func FinishChannelSync(ctx context.Context, tx pgx.Tx, cs *ChannelSync) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update channel_sync set cursor = $2, finished_at = now(), error = null where id = $1`, []interface{}{cs.ID, cs.Cursor})
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// FailedEvent is a Discord event that could not be stored, kept to be retried until it succeeds or is
+// discarded. Payload is the JSON-encoded event and Error is the error of the last attempt.
+type FailedEvent struct {
+	IdentifiableEntity
+	Type          string
+	Payload       []byte
+	Error         string
+	Attempts      int32
+	FailedAt      time.Time
+	NextAttemptAt time.Time
+}
+
+// CreateFailedEvent stores a failed event.
+func CreateFailedEvent(ctx context.Context, tx pgx.Tx, e *FailedEvent) error {
+	return query(ctx, tx, `insert into failed_event (type, payload, error, attempts, failed_at, next_attempt_at) values ($1, $2, $3, $4, now(), $5) returning id, failed_at`, []interface{}{e.Type, e.Payload, e.Error, e.Attempts, e.NextAttemptAt}, []interface{}{&e.ID, &e.FailedAt})
+}
+
+// UpdateFailedEvent records another failed attempt to apply the event.
+func UpdateFailedEvent(ctx context.Context, tx pgx.Tx, e *FailedEvent) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update failed_event set error = $2, attempts = $3, next_attempt_at = $4 where id = $1`, []interface{}{e.ID, e.Error, e.Attempts, e.NextAttemptAt})
+}
+
+// FindFailedEvents returns all failed events, oldest first.
+func FindFailedEvents(ctx context.Context, tx pgx.Tx) ([]*FailedEvent, error) {
+	return findFailedEvents(ctx, tx, `select id, type, payload, error, attempts, failed_at, next_attempt_at from failed_event order by id`)
+}
+
+// FindDueFailedEvents returns failed events due to be retried, oldest first.
+func FindDueFailedEvents(ctx context.Context, tx pgx.Tx) ([]*FailedEvent, error) {
+	return findFailedEvents(ctx, tx, `select id, type, payload, error, attempts, failed_at, next_attempt_at from failed_event where next_attempt_at <= now() order by id`)
+}
+
+// ReplayFailedEvent schedules failed event with the specified ID to be retried right away. Returns false
+// if there is no such event.
+func ReplayFailedEvent(ctx context.Context, tx pgx.Tx, ID ID) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update failed_event set next_attempt_at = now() where id = $1`, []interface{}{ID})
+}
+
+// ReplayFailedEvents schedules all failed events to be retried right away.
+func ReplayFailedEvents(ctx context.Context, tx pgx.Tx) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update failed_event set next_attempt_at = now()`, nil)
+}
+
+// DeleteFailedEvent deletes failed event with the specified ID, either once it succeeded or to discard
+// it. Returns false if there is no such event.
+func DeleteFailedEvent(ctx context.Context, tx pgx.Tx, ID ID) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `delete from failed_event where id = $1`, []interface{}{ID})
+}
+
+// DeleteFailedEvents deletes all failed events.
+func DeleteFailedEvents(ctx context.Context, tx pgx.Tx) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `delete from failed_event`, nil)
+}
+
+func findFailedEvents(ctx context.Context, tx pgx.Tx, sql string, args ...interface{}) ([]*FailedEvent, error) {
+	e := make([]*FailedEvent, 0, 8)
+	q, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		fe := &FailedEvent{}
+		if err := q.Scan(&fe.ID, &fe.Type, &fe.Payload, &fe.Error, &fe.Attempts, &fe.FailedAt, &fe.NextAttemptAt); err != nil {
+			return nil, err
+		}
+
+		e = append(e, fe)
+	}
+
+	return e, q.Err()
+}
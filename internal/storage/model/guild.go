@@ -25,3 +25,36 @@ func FindOrCreateGuild(ctx context.Context, tx pgx.Tx, g *Guild) error {
 func FindGuild(ctx context.Context, tx pgx.Tx, g *Guild) error {
 	return query(ctx, tx, `select id from guild where discord_id = $1`, []interface{}{g.DiscordID}, []interface{}{&g.ID})
 }
+
+// DeleteGuild deletes guild along with all its channels and their posts.
+func DeleteGuild(ctx context.Context, tx pgx.Tx, g *Guild) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `delete from guild where discord_id = $1`, []interface{}{g.DiscordID})
+}
+
+// UpdateGuildName stores name of the specified guild, returning false if the guild is not in database.
+func UpdateGuildName(ctx context.Context, tx pgx.Tx, g *Guild, name string) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update guild set name = $2 where discord_id = $1`, []interface{}{g.DiscordID, name})
+}
+
+// FindGuildNames finds names of guilds with the specified Discord IDs, keyed by Discord ID. Guilds whose
+// name has not been stored yet are omitted.
+func FindGuildNames(ctx context.Context, tx pgx.Tx, guilds []Snowflake) (map[Snowflake]string, error) {
+	names := make(map[Snowflake]string, len(guilds))
+	q, err := tx.Query(ctx, `select discord_id, name from guild where discord_id = any($1) and name is not null`, guilds)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Snowflake
+		var name string
+		if err := q.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+
+		names[id] = name
+	}
+
+	return names, q.Err()
+}
@@ -18,10 +18,13 @@ type Image struct {
 	Width  uint32
 	Height uint32
 	Size   uint64
+	// ThumbnailAttempts is the number of failed attempts to create thumbnails of the image, loaded only by
+	// FindImagesWithoutThumbnails.
+	ThumbnailAttempts int32
 }
 
 func NewImage(ID ID, postID Ref, url string, width uint32, height uint32, size uint64) *Image {
-	return &Image{IdentifiableEntity{ID}, postID, url, width, height, size}
+	return &Image{IdentifiableEntity{ID}, postID, url, width, height, size, 0}
 }
 
 func WrapDiscordAttachment(at *discordgo.MessageAttachment) *Image {
@@ -63,9 +66,30 @@ func CreateImage(ctx context.Context, tx pgx.Tx, im *Image) error {
 	return query(ctx, tx, `insert into image (post_id, url, width, height, size) values ($1, $2, $3, $4, $5) returning id`, []interface{}{im.PostID, im.URL, im.Width, im.Height, im.Size}, []interface{}{&im.ID})
 }
 
+// FindPostsImages returns images of the specified posts keyed by post ID.
+func FindPostsImages(ctx context.Context, tx pgx.Tx, posts []*Post) (map[Ref][]*Image, error) {
+	images := make(map[Ref][]*Image, len(posts))
+	q, err := tx.Query(ctx, `select id, post_id, url, width, height, size from image where post_id = any($1) order by id`, postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		im := &Image{}
+		if err := q.Scan(&im.ID, &im.PostID, &im.URL, &im.Width, &im.Height, &im.Size); err != nil {
+			return nil, err
+		}
+
+		images[im.PostID] = append(images[im.PostID], im)
+	}
+
+	return images, q.Err()
+}
+
 func FindImages(ctx context.Context, tx pgx.Tx, p *Post) ([]*Image, error) {
 	images := make([]*Image, 0, 4)
-	q, err := tx.Query(ctx, `select url, width, height, size from image where post_id = $1`, p.ID)
+	q, err := tx.Query(ctx, `select id, post_id, url, width, height, size from image where post_id = $1 order by id`, p.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +97,7 @@ func FindImages(ctx context.Context, tx pgx.Tx, p *Post) ([]*Image, error) {
 	defer q.Close()
 	for q.Next() {
 		im := &Image{}
-		if err := q.Scan(&im.URL, &im.Width, &im.Height, &im.Size); err != nil {
+		if err := q.Scan(&im.ID, &im.PostID, &im.URL, &im.Width, &im.Height, &im.Size); err != nil {
 			return nil, err
 		}
 
@@ -81,4 +105,4 @@ func FindImages(ctx context.Context, tx pgx.Tx, p *Post) ([]*Image, error) {
 	}
 
 	return images, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Nickname is a guild nickname of a user, where Nickname is an empty string if the user has none.
+type Nickname struct {
+	GuildID    Snowflake
+	Nickname   string
+	RecordedAt time.Time
+}
+
+// UpdateMemberNickname stores nickname of the specified user in the specified guild, recording it in
+// history if it changed. Returns true if the nickname changed.
+func UpdateMemberNickname(ctx context.Context, tx pgx.Tx, u *User, g *Guild, nickname string) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `with m as (insert into member (user_id, guild_id, nickname, updated_at) values ($1, $2, nullif($3, ''), now()) on conflict (user_id, guild_id) do update set nickname = excluded.nickname, updated_at = excluded.updated_at where member.nickname is distinct from excluded.nickname returning id, nickname) insert into member_history (member_id, nickname, recorded_at) select id, nickname, now() from m`, []interface{}{u.ID, g.ID, nickname})
+}
+
+// FindNicknames returns current nicknames of the specified user in every guild.
+func FindNicknames(ctx context.Context, tx pgx.Tx, u *User) ([]*Nickname, error) {
+	return findNicknames(ctx, tx, `select g.discord_id, coalesce(m.nickname, ''), m.updated_at from member m join guild g on g.id = m.guild_id join "user" u on u.id = m.user_id where u.discord_id = $1 order by g.discord_id`, u.DiscordID)
+}
+
+// FindNicknameHistory returns all recorded nicknames of the specified user in every guild, newest first.
+func FindNicknameHistory(ctx context.Context, tx pgx.Tx, u *User) ([]*Nickname, error) {
+	return findNicknames(ctx, tx, `select g.discord_id, coalesce(h.nickname, ''), h.recorded_at from member_history h join member m on m.id = h.member_id join guild g on g.id = m.guild_id join "user" u on u.id = m.user_id where u.discord_id = $1 order by h.recorded_at desc, h.id desc`, u.DiscordID)
+}
+
+// FindPostsNicknames finds current nicknames of authors of the specified posts in guilds of the posts,
+// keyed by post ID. Posts whose author has no nickname are omitted.
+func FindPostsNicknames(ctx context.Context, tx pgx.Tx, posts []*Post) (map[Ref]string, error) {
+	nicknames := make(map[Ref]string, len(posts))
+	q, err := tx.Query(ctx, `select p.id, m.nickname from post p join channel c on c.id = p.channel_id join member m on m.guild_id = c.guild_id and m.user_id = p.user_id where p.id = any($1) and m.nickname is not null`, postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Ref
+		var nickname string
+		if err := q.Scan(&id, &nickname); err != nil {
+			return nil, err
+		}
+
+		nicknames[id] = nickname
+	}
+
+	return nicknames, q.Err()
+}
+
+func findNicknames(ctx context.Context, tx pgx.Tx, sql string, args ...interface{}) ([]*Nickname, error) {
+	n := make([]*Nickname, 0, 4)
+	q, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		nn := &Nickname{}
+		if err := q.Scan(&nn.GuildID, &nn.Nickname, &nn.RecordedAt); err != nil {
+			return nil, err
+		}
+
+		n = append(n, nn)
+	}
+
+	return n, q.Err()
+}
@@ -12,14 +12,27 @@ type Post struct {
 	ChannelID Ref
 	UserID    Ref
 	Message   string
+	CreatedAt NullableTime
+	EditedAt  NullableTime
 }
 
 func NewPost(ID ID, discordID Snowflake, channelID Ref, userID Ref, message string) *Post {
-	return &Post{IdentifiableDiscordEntity{IdentifiableEntity{ID}, discordID}, channelID, userID, message}
+	return &Post{IdentifiableDiscordEntity{IdentifiableEntity{ID}, discordID}, channelID, userID, message, NullableTime{}, NullableTime{}}
 }
 
+// WrapDiscordMessage creates a post from Discord message, taking creation time from the snowflake if the
+// message has no timestamp.
 func WrapDiscordMessage(m *discordgo.Message) *Post {
-	return NewPost(0, MustParseSnowflake(m.ID), 0, 0, m.Content)
+	p := NewPost(0, MustParseSnowflake(m.ID), 0, 0, m.Content)
+	if t, err := m.Timestamp.Parse(); err == nil {
+		p.CreatedAt = NullableTime{Time: t, Valid: true}
+	} else {
+		p.CreatedAt = NullableTime{Time: SnowflakeTime(p.DiscordID), Valid: true}
+	}
+	if t, err := m.EditedTimestamp.Parse(); err == nil {
+		p.EditedAt = NullableTime{Time: t, Valid: true}
+	}
+	return p
 }
 
 func WrapMessageID(ID string) *Post {
@@ -27,16 +40,16 @@ func WrapMessageID(ID string) *Post {
 }
 
 func CreatePost(ctx context.Context, tx pgx.Tx, p *Post) error {
-	return query(ctx, tx, `insert into post (discord_id, channel_id, user_id, message) values ($1, $2, $3, $4) returning id`, []interface{}{p.DiscordID, p.ChannelID, p.UserID, p.Message}, []interface{}{&p.ID})
+	return query(ctx, tx, `insert into post (discord_id, channel_id, user_id, message, created_at, edited_at) values ($1, $2, $3, $4, $5, $6) returning id`, []interface{}{p.DiscordID, p.ChannelID, p.UserID, p.Message, p.CreatedAt, p.EditedAt}, []interface{}{&p.ID})
 }
 
 func FindPost(ctx context.Context, tx pgx.Tx, p *Post) error {
-	return query(ctx, tx, `select id, channel_id, user_id, message from post where discord_id = $1`, []interface{}{p.DiscordID}, []interface{}{&p.ID, &p.ChannelID, &p.UserID, &p.Message})
+	return query(ctx, tx, `select id, channel_id, user_id, message, created_at, edited_at from post where discord_id = $1`, []interface{}{p.DiscordID}, []interface{}{&p.ID, &p.ChannelID, &p.UserID, &p.Message, &p.CreatedAt, &p.EditedAt})
 }
 
 func FindPosts(ctx context.Context, tx pgx.Tx, offset uint32, limit uint64) ([]*Post, error) {
 	p := make([]*Post, 0, limit)
-	q, err := tx.Query(ctx, `select id, channel_id, user_id, message from post order by discord_id desc limit $1 offset $2`, limit, offset)
+	q, err := tx.Query(ctx, `select id, discord_id, channel_id, user_id, message, created_at, edited_at from post order by discord_id desc limit $1 offset $2`, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +57,7 @@ func FindPosts(ctx context.Context, tx pgx.Tx, offset uint32, limit uint64) ([]*
 	defer q.Close()
 	for q.Next() {
 		ep := &Post{}
-		if err := q.Scan(&ep.ID, &ep.ChannelID, &ep.UserID, &ep.Message); err != nil {
+		if err := q.Scan(&ep.ID, &ep.DiscordID, &ep.ChannelID, &ep.UserID, &ep.Message, &ep.CreatedAt, &ep.EditedAt); err != nil {
 			return nil, err
 		}
 
@@ -54,15 +67,129 @@ func FindPosts(ctx context.Context, tx pgx.Tx, offset uint32, limit uint64) ([]*
 	return p, nil
 }
 
+// postsReactedByUser selects posts reacted to by user with Discord ID $1, optionally only with emoji of
+// Discord ID $2 or Unicode emoji named $3.
+const postsReactedByUser = `from post p join reaction r on p.id = r.post_id join emoji e on e.id = r.emoji_id join user_reaction ur on r.id = ur.reaction_id join "user" u on u.id = ur.user_id where u.discord_id = $1 and ($2::bigint is null or e.discord_id = $2) and ($3::text is null or (e.discord_id is null and e.name = $3))`
+
+// emojiFilterArgs returns arguments matching $2 and $3 of postsReactedByUser for the specified emoji,
+// which can be nil.
+func emojiFilterArgs(em *Emoji) (NullableSnowflake, *string) {
+	if em == nil {
+		return NullableSnowflake{}, nil
+	}
+	if em.DiscordID.Valid {
+		return em.DiscordID, nil
+	}
+	return NullableSnowflake{}, &em.Name
+}
+
+// FindPostsReactedByUser returns posts the specified user reacted to, newest first. If emoji is not nil,
+// only reactions with that emoji are considered.
+func FindPostsReactedByUser(ctx context.Context, tx pgx.Tx, u *User, em *Emoji, offset uint32, limit uint64) ([]*Post, error) {
+	emojiID, emojiName := emojiFilterArgs(em)
+	p := make([]*Post, 0, limit)
+	q, err := tx.Query(ctx, `select distinct p.id, p.discord_id, p.channel_id, p.user_id, p.message, p.created_at, p.edited_at `+postsReactedByUser+` order by p.discord_id desc limit $4 offset $5`, u.DiscordID, emojiID, emojiName, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		ep := &Post{}
+		if err := q.Scan(&ep.ID, &ep.DiscordID, &ep.ChannelID, &ep.UserID, &ep.Message, &ep.CreatedAt, &ep.EditedAt); err != nil {
+			return nil, err
+		}
+
+		p = append(p, ep)
+	}
+
+	return p, q.Err()
+}
+
+func CountPostsReactedByUser(ctx context.Context, tx pgx.Tx, u *User, em *Emoji) (uint64, error) {
+	emojiID, emojiName := emojiFilterArgs(em)
+	var count uint64
+	if err := query(ctx, tx, `select count(distinct p.id) `+postsReactedByUser, []interface{}{u.DiscordID, emojiID, emojiName}, []interface{}{&count}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func CountPosts(ctx context.Context, tx pgx.Tx) (uint64, error) {
+	var count uint64
+	if err := query(ctx, tx, `select count(*) from post`, nil, []interface{}{&count}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// EstimatePosts returns number of posts estimated by PostgreSQL planner statistics, which is cheap
+// compared to CountPosts, but may be off by a few percent.
+func EstimatePosts(ctx context.Context, tx pgx.Tx) (uint64, error) {
+	var count uint64
+	if err := query(ctx, tx, `select greatest(reltuples, 0)::bigint from pg_class where oid = 'post'::regclass`, nil, []interface{}{&count}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// PostRefs holds Discord IDs of the guild, channel and user referenced by a post.
+type PostRefs struct {
+	GuildID   Snowflake
+	ChannelID Snowflake
+	UserID    Snowflake
+}
+
+// FindPostsRefs returns references of the specified posts keyed by post ID.
+func FindPostsRefs(ctx context.Context, tx pgx.Tx, posts []*Post) (map[Ref]*PostRefs, error) {
+	refs := make(map[Ref]*PostRefs, len(posts))
+	q, err := tx.Query(ctx, `select p.id, g.discord_id, c.discord_id, u.discord_id from post p join channel c on c.id = p.channel_id join guild g on g.id = c.guild_id join "user" u on u.id = p.user_id where p.id = any($1)`, postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Ref
+		r := &PostRefs{}
+		if err := q.Scan(&id, &r.GuildID, &r.ChannelID, &r.UserID); err != nil {
+			return nil, err
+		}
+
+		refs[id] = r
+	}
+
+	return refs, q.Err()
+}
+
+// postIDs returns IDs of the specified posts, to be passed as an array parameter.
+func postIDs(posts []*Post) []ID {
+	ids := make([]ID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
 func UpdatePost(ctx context.Context, tx pgx.Tx, p *Post) (bool, error) {
 	return queryUpdateDelete(
 		ctx,
 		tx,
-		`update post set message = $2 where discord_id = $1`,
-		[]interface{}{p.DiscordID, p.Message},
+		`update post set message = $2, created_at = coalesce(created_at, $3), edited_at = coalesce($4, edited_at) where discord_id = $1`,
+		[]interface{}{p.DiscordID, p.Message, p.CreatedAt, p.EditedAt},
 	)
 }
 
+// BackfillPostCreatedAt sets creation time of at most limit posts missing it from their snowflakes,
+// returning the number of posts updated.
+func BackfillPostCreatedAt(ctx context.Context, tx pgx.Tx, limit uint64) (int64, error) {
+	tag, err := tx.Exec(ctx, `update post set created_at = to_timestamp(((discord_id >> 22) + $2) / 1000.0) where id in (select id from post where created_at is null limit $1)`, limit, discordEpoch)
+	return tag.RowsAffected(), err
+}
+
 func DeletePost(ctx context.Context, tx pgx.Tx, p *Post) (bool, error) {
 	return queryUpdateDelete(
 		ctx,
@@ -76,6 +203,34 @@ func DeletePostImages(ctx context.Context, tx pgx.Tx, p *Post) (bool, error) {
 	return queryUpdateDelete(ctx, tx, `delete from image where post_id = $1`, []interface{}{p.ID})
 }
 
+// FindLatestPost finds the most recent post in the specified channel, leaving p.ID zero if the channel has
+// no posts.
+func FindLatestPost(ctx context.Context, tx pgx.Tx, c *Channel, p *Post) error {
+	return query(ctx, tx, `select id, discord_id, channel_id, user_id, message, created_at, edited_at from post where channel_id = $1 order by discord_id desc limit 1`, []interface{}{c.ID}, []interface{}{&p.ID, &p.DiscordID, &p.ChannelID, &p.UserID, &p.Message, &p.CreatedAt, &p.EditedAt})
+}
+
+// FindChannelPostsAfter returns posts in the specified channel with Discord ID greater than after, oldest
+// first.
+func FindChannelPostsAfter(ctx context.Context, tx pgx.Tx, c *Channel, after Snowflake) ([]*Post, error) {
+	p := make([]*Post, 0, 16)
+	q, err := tx.Query(ctx, `select id, discord_id, channel_id, user_id, message, created_at, edited_at from post where channel_id = $1 and discord_id > $2 order by discord_id`, c.ID, after)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		ep := &Post{}
+		if err := q.Scan(&ep.ID, &ep.DiscordID, &ep.ChannelID, &ep.UserID, &ep.Message, &ep.CreatedAt, &ep.EditedAt); err != nil {
+			return nil, err
+		}
+
+		p = append(p, ep)
+	}
+
+	return p, q.Err()
+}
+
 func IsChannelEmpty(ctx context.Context, tx pgx.Tx, c *Channel) (bool, error) {
 	var i int
 	if err := query(ctx, tx, `select 1 from post where channel_id = $1 limit 1`, []interface{}{c.ID}, []interface{}{&i}); err != nil {
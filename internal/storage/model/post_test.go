@@ -0,0 +1,164 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/storagetest"
+)
+
+// createReactedPost creates a post in a new guild and channel, reacted to by user with emoji.
+func createReactedPost(ctx context.Context, tx pgx.Tx, discordID Snowflake, createdAt time.Time, u *User, em *Emoji) (*Post, error) {
+	g := NewGuild(0, 1)
+	if err := FindOrCreateGuild(ctx, tx, g); err != nil {
+		return nil, err
+	}
+	ch := &Channel{IdentifiableDiscordEntity{IdentifiableEntity{}, 2}, g.ID}
+	if err := FindOrCreateChannel(ctx, tx, ch); err != nil {
+		return nil, err
+	}
+
+	p := NewPost(0, discordID, ch.ID, u.ID, "post")
+	p.CreatedAt = NullableTime{Time: createdAt, Valid: true}
+	if err := CreatePost(ctx, tx, p); err != nil {
+		return nil, err
+	}
+
+	r := &Reaction{PostID: p.ID, EmojiID: em.ID}
+	if err := FindOrCreateReaction(ctx, tx, r); err != nil {
+		return nil, err
+	}
+	return p, CreateUserReaction(ctx, tx, &UserReaction{ReactionID: r.ID, UserID: u.ID})
+}
+
+func TestFindPostsReactedByUser(t *testing.T) {
+	s := storagetest.Open(t)
+	ctx := context.Background()
+	createdAt := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := s.Begin(ctx, func(tx pgx.Tx) error {
+		u := NewUser(0, 3)
+		if err := FindOrCreateUser(ctx, tx, u); err != nil {
+			t.Fatalf("failed to create user: %s", err)
+		}
+		em := NewEmoji(0, NullableSnowflake{}, "👍")
+		if err := FindOrCreateEmoji(ctx, tx, em); err != nil {
+			t.Fatalf("failed to create emoji: %s", err)
+		}
+		for i := Snowflake(10); i < 13; i++ {
+			if _, err := createReactedPost(ctx, tx, i, createdAt, u, em); err != nil {
+				t.Fatalf("failed to create post: %s", err)
+			}
+		}
+
+		for _, filter := range []*Emoji{nil, em} {
+			posts, err := FindPostsReactedByUser(ctx, tx, u, filter, 1, 10)
+			if err != nil {
+				t.Fatalf("failed to find posts: %s", err)
+			}
+			if len(posts) != 2 {
+				t.Fatalf("expected 2 posts, got %d", len(posts))
+			}
+			for i, p := range posts {
+				if want := Snowflake(11 - i); p.DiscordID != want {
+					t.Errorf("expected post %d at %d, got %d", want, i, p.DiscordID)
+				}
+				if !p.CreatedAt.Valid || !p.CreatedAt.Time.Equal(createdAt) {
+					t.Errorf("expected post %d created at %s, got %v", p.DiscordID, createdAt, p.CreatedAt)
+				}
+			}
+		}
+
+		other := NewEmoji(0, NullableSnowflake{}, "👎")
+		posts, err := FindPostsReactedByUser(ctx, tx, u, other, 0, 10)
+		if err != nil {
+			t.Fatalf("failed to find posts: %s", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("expected no posts with other emoji, got %d", len(posts))
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to begin transaction: %s", err)
+	}
+}
+
+func TestFindPostsRelated(t *testing.T) {
+	s := storagetest.Open(t)
+	ctx := context.Background()
+
+	if err := s.Begin(ctx, func(tx pgx.Tx) error {
+		u := NewUser(0, 3)
+		if err := FindOrCreateUser(ctx, tx, u); err != nil {
+			t.Fatalf("failed to create user: %s", err)
+		}
+		em := NewEmoji(0, NullableSnowflake{}, "👍")
+		if err := FindOrCreateEmoji(ctx, tx, em); err != nil {
+			t.Fatalf("failed to create emoji: %s", err)
+		}
+		var posts []*Post
+		for i := Snowflake(10); i < 12; i++ {
+			p, err := createReactedPost(ctx, tx, i, time.Now(), u, em)
+			if err != nil {
+				t.Fatalf("failed to create post: %s", err)
+			}
+			posts = append(posts, p)
+		}
+		im := NewImage(0, posts[0].ID, "https://cdn.example.com/1.png", 64, 48, 1024)
+		if err := CreateImage(ctx, tx, im); err != nil {
+			t.Fatalf("failed to create image: %s", err)
+		}
+		for _, width := range []uint32{32, 16} {
+			if err := CreateThumbnail(ctx, tx, NewThumbnail(0, im.ID, width, width, "t.png")); err != nil {
+				t.Fatalf("failed to create thumbnail: %s", err)
+			}
+		}
+
+		refs, err := FindPostsRefs(ctx, tx, posts)
+		if err != nil {
+			t.Fatalf("failed to find refs: %s", err)
+		}
+		for _, p := range posts {
+			if r := refs[p.ID]; r == nil || *r != (PostRefs{1, 2, 3}) {
+				t.Errorf("expected refs of post %d to be guild 1, channel 2 and user 3, got %+v", p.DiscordID, r)
+			}
+		}
+
+		images, err := FindPostsImages(ctx, tx, posts)
+		if err != nil {
+			t.Fatalf("failed to find images: %s", err)
+		}
+		if len(images) != 1 || len(images[posts[0].ID]) != 1 {
+			t.Fatalf("expected one image of the first post, got %v", images)
+		}
+		thumbs, err := FindImagesThumbnails(ctx, tx, images[posts[0].ID])
+		if err != nil {
+			t.Fatalf("failed to find thumbnails: %s", err)
+		}
+		if th := thumbs[im.ID]; len(th) != 2 || th[0].Width != 16 || th[1].Width != 32 {
+			t.Errorf("expected 2 thumbnails ordered by width, got %v", th)
+		}
+
+		counts, err := CountPostsUserReactions(ctx, tx, posts)
+		if err != nil {
+			t.Fatalf("failed to count reactions: %s", err)
+		}
+		details, err := FindPostsReactionCounts(ctx, tx, posts)
+		if err != nil {
+			t.Fatalf("failed to find reaction counts: %s", err)
+		}
+		for _, p := range posts {
+			if counts[p.ID] != 1 {
+				t.Errorf("expected 1 user reacting to post %d, got %d", p.DiscordID, counts[p.ID])
+			}
+			if d := details[p.ID]; len(d) != 1 || d[0].Emoji.Name != em.Name || d[0].Count != 1 {
+				t.Errorf("expected one reaction to post %d, got %v", p.DiscordID, d)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to begin transaction: %s", err)
+	}
+}
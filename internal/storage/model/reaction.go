@@ -32,3 +32,52 @@ func DeleteAllReactions(ctx context.Context, tx pgx.Tx, p *Post) (bool, error) {
 func FindOrCreateReaction(ctx context.Context, tx pgx.Tx, r *Reaction) error {
 	return query(ctx, tx, `with e as (insert into reaction (post_id, emoji_id) values ($1, $2) on conflict do nothing returning id) select id from e union select id from reaction where post_id = $1 and emoji_id = $2`, []interface{}{r.PostID, r.EmojiID}, []interface{}{&r.ID})
 }
+
+// ReactionCount is a number of distinct users that reacted to a post with an emoji.
+type ReactionCount struct {
+	Emoji *Emoji
+	Count uint32
+}
+
+func FindReactionCounts(ctx context.Context, tx pgx.Tx, p *Post) ([]*ReactionCount, error) {
+	counts := make([]*ReactionCount, 0, 4)
+	q, err := tx.Query(ctx, `select e.id, e.discord_id, e.name, count(ur.id) from reaction r join emoji e on e.id = r.emoji_id join user_reaction ur on r.id = ur.reaction_id where r.post_id = $1 group by e.id order by count(ur.id) desc, e.id`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		rc := &ReactionCount{Emoji: &Emoji{}}
+		if err := q.Scan(&rc.Emoji.ID, &rc.Emoji.DiscordID, &rc.Emoji.Name, &rc.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, rc)
+	}
+
+	return counts, q.Err()
+}
+
+// FindPostsReactionCounts returns reaction counts of the specified posts keyed by post ID, ordered like
+// FindReactionCounts.
+func FindPostsReactionCounts(ctx context.Context, tx pgx.Tx, posts []*Post) (map[Ref][]*ReactionCount, error) {
+	counts := make(map[Ref][]*ReactionCount, len(posts))
+	q, err := tx.Query(ctx, `select r.post_id, e.id, e.discord_id, e.name, count(ur.id) from reaction r join emoji e on e.id = r.emoji_id join user_reaction ur on r.id = ur.reaction_id where r.post_id = any($1) group by r.post_id, e.id order by count(ur.id) desc, e.id`, postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Ref
+		rc := &ReactionCount{Emoji: &Emoji{}}
+		if err := q.Scan(&id, &rc.Emoji.ID, &rc.Emoji.DiscordID, &rc.Emoji.Name, &rc.Count); err != nil {
+			return nil, err
+		}
+
+		counts[id] = append(counts[id], rc)
+	}
+
+	return counts, q.Err()
+}
@@ -3,8 +3,22 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
+// discordEpoch is the first millisecond of 2015 in Unix milliseconds, which Discord snowflake timestamps
+// are relative to.
+const discordEpoch = 1420070400000
+
+// ParseSnowflake parses Snowflake ID string.
+func ParseSnowflake(s string) (Snowflake, error) {
+	val, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse Snowflake ID string: %w", err)
+	}
+	return val, nil
+}
+
 func MustParseSnowflake(s string) Snowflake {
 	val, err := strconv.ParseUint(s, 10, 64)
 	if err != nil {
@@ -12,3 +26,18 @@ func MustParseSnowflake(s string) Snowflake {
 	}
 	return val
 }
+
+// SnowflakeTime returns the time at which the entity identified by the specified snowflake was created.
+func SnowflakeTime(s Snowflake) time.Time {
+	return time.Unix(0, int64(s>>22+discordEpoch)*int64(time.Millisecond)).UTC()
+}
+
+// TimeSnowflake returns the smallest snowflake of an entity created at the specified time, suitable for
+// use as a pagination cursor.
+func TimeSnowflake(t time.Time) Snowflake {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if ms < discordEpoch {
+		return 0
+	}
+	return Snowflake(ms-discordEpoch) << 22
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// PosterStats is a number of posts made by a user.
+type PosterStats struct {
+	User  *User
+	Posts uint64
+}
+
+// FindTopPosters returns up to limit users with the most posts, most productive first.
+func FindTopPosters(ctx context.Context, tx pgx.Tx, limit uint64) ([]*PosterStats, error) {
+	stats := make([]*PosterStats, 0, limit)
+	q, err := tx.Query(ctx, `select u.id, u.discord_id, count(distinct p.id) as posts from "user" u join post p on u.id = p.user_id group by u.id order by posts desc, u.id limit $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		ps := &PosterStats{User: &User{}}
+		if err := q.Scan(&ps.User.ID, &ps.User.DiscordID, &ps.Posts); err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, ps)
+	}
+
+	return stats, q.Err()
+}
+
+func CountUserPosts(ctx context.Context, tx pgx.Tx, u *User) (uint64, error) {
+	var count uint64
+	if err := query(ctx, tx, `select count(*) from post p join "user" u on u.id = p.user_id where u.discord_id = $1`, []interface{}{u.DiscordID}, []interface{}{&count}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// FindMostReactedPost finds post with the most distinct users reacting to it (as counted by
+// CountUserReactions) in the specified channel, leaving p.ID zero if the channel has no reacted posts.
+func FindMostReactedPost(ctx context.Context, tx pgx.Tx, c *Channel, p *Post) (uint32, error) {
+	var count uint32
+	if err := query(ctx, tx, `select p.id, p.discord_id, p.channel_id, p.user_id, p.message, p.created_at, p.edited_at, count(distinct ur.user_id) as reactions from post p join channel c on c.id = p.channel_id join reaction r on p.id = r.post_id join user_reaction ur on r.id = ur.reaction_id where c.discord_id = $1 group by p.id order by reactions desc, p.discord_id desc limit 1`, []interface{}{c.DiscordID}, []interface{}{&p.ID, &p.DiscordID, &p.ChannelID, &p.UserID, &p.Message, &p.CreatedAt, &p.EditedAt, &count}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
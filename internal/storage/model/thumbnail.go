@@ -0,0 +1,106 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type Thumbnail struct {
+	IdentifiableEntity
+	ImageID Ref
+	Width   uint32
+	Height  uint32
+	Path    string
+}
+
+func NewThumbnail(ID ID, imageID Ref, width uint32, height uint32, path string) *Thumbnail {
+	return &Thumbnail{IdentifiableEntity{ID}, imageID, width, height, path}
+}
+
+func CreateThumbnail(ctx context.Context, tx pgx.Tx, t *Thumbnail) error {
+	return query(ctx, tx, `insert into thumbnail (image_id, width, height, path) values ($1, $2, $3, $4) on conflict (image_id, width) do update set height = excluded.height, path = excluded.path returning id`, []interface{}{t.ImageID, t.Width, t.Height, t.Path}, []interface{}{&t.ID})
+}
+
+// FindImagesThumbnails returns thumbnails of the specified images keyed by image ID, smallest first.
+func FindImagesThumbnails(ctx context.Context, tx pgx.Tx, images []*Image) (map[Ref][]*Thumbnail, error) {
+	ids := make([]ID, len(images))
+	for i, im := range images {
+		ids[i] = im.ID
+	}
+
+	thumbnails := make(map[Ref][]*Thumbnail, len(images))
+	q, err := tx.Query(ctx, `select id, image_id, width, height, path from thumbnail where image_id = any($1) order by width`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		t := &Thumbnail{}
+		if err := q.Scan(&t.ID, &t.ImageID, &t.Width, &t.Height, &t.Path); err != nil {
+			return nil, err
+		}
+
+		thumbnails[t.ImageID] = append(thumbnails[t.ImageID], t)
+	}
+
+	return thumbnails, q.Err()
+}
+
+// FindThumbnailPaths returns which of the specified thumbnail file paths belong to a stored thumbnail.
+func FindThumbnailPaths(ctx context.Context, tx pgx.Tx, paths []string) (map[string]bool, error) {
+	found := make(map[string]bool, len(paths))
+	q, err := tx.Query(ctx, `select path from thumbnail where path = any($1)`, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var path string
+		if err := q.Scan(&path); err != nil {
+			return nil, err
+		}
+
+		found[path] = true
+	}
+
+	return found, q.Err()
+}
+
+// FindImagesWithoutThumbnails returns up to limit images that have not been processed by the thumbnail
+// pipeline yet, newest first. Images that failed are returned once they are due to be retried (see
+// DelayImageThumbnails.)
+func FindImagesWithoutThumbnails(ctx context.Context, tx pgx.Tx, limit uint64) ([]*Image, error) {
+	images := make([]*Image, 0, limit)
+	q, err := tx.Query(ctx, `select id, post_id, url, width, height, size, thumbnail_attempts from image where thumbnailed_at is null and (thumbnail_retry_at is null or thumbnail_retry_at <= now()) order by id desc limit $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		im := &Image{}
+		if err := q.Scan(&im.ID, &im.PostID, &im.URL, &im.Width, &im.Height, &im.Size, &im.ThumbnailAttempts); err != nil {
+			return nil, err
+		}
+
+		images = append(images, im)
+	}
+
+	return images, q.Err()
+}
+
+// MarkImageThumbnailed marks image as processed by the thumbnail pipeline, so it is not picked up again
+// regardless of whether any thumbnails could be produced for it.
+func MarkImageThumbnailed(ctx context.Context, tx pgx.Tx, im *Image) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update image set thumbnailed_at = now() where id = $1`, []interface{}{im.ID})
+}
+
+// DelayImageThumbnails records a failed attempt to create thumbnails of image, which is not returned by
+// FindImagesWithoutThumbnails again until retryAt.
+func DelayImageThumbnails(ctx context.Context, tx pgx.Tx, im *Image, retryAt time.Time) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update image set thumbnail_attempts = thumbnail_attempts + 1, thumbnail_retry_at = $2 where id = $1`, []interface{}{im.ID, retryAt})
+}
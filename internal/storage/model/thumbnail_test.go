@@ -0,0 +1,62 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"pkg.mon.icu/monicu/internal/storage/storagetest"
+)
+
+func TestDelayImageThumbnails(t *testing.T) {
+	s := storagetest.Open(t)
+	ctx := context.Background()
+
+	if err := s.Begin(ctx, func(tx pgx.Tx) error {
+		u := NewUser(0, 3)
+		if err := FindOrCreateUser(ctx, tx, u); err != nil {
+			t.Fatalf("failed to create user: %s", err)
+		}
+		em := NewEmoji(0, NullableSnowflake{}, "👍")
+		if err := FindOrCreateEmoji(ctx, tx, em); err != nil {
+			t.Fatalf("failed to create emoji: %s", err)
+		}
+		p, err := createReactedPost(ctx, tx, 10, time.Now(), u, em)
+		if err != nil {
+			t.Fatalf("failed to create post: %s", err)
+		}
+		failing, pending := NewImage(0, p.ID, "https://cdn.example.com/1.png", 64, 48, 1024), NewImage(0, p.ID, "https://cdn.example.com/2.png", 64, 48, 1024)
+		for _, im := range []*Image{pending, failing} {
+			if err := CreateImage(ctx, tx, im); err != nil {
+				t.Fatalf("failed to create image: %s", err)
+			}
+		}
+
+		if _, err := DelayImageThumbnails(ctx, tx, failing, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("failed to delay image: %s", err)
+		}
+		images, err := FindImagesWithoutThumbnails(ctx, tx, 10)
+		if err != nil {
+			t.Fatalf("failed to find images: %s", err)
+		}
+		if len(images) != 1 || images[0].ID != pending.ID {
+			t.Errorf("expected only image %d not delayed, got %v", pending.ID, images)
+		}
+
+		// Due to be retried
+		if _, err := DelayImageThumbnails(ctx, tx, failing, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("failed to delay image: %s", err)
+		}
+		images, err = FindImagesWithoutThumbnails(ctx, tx, 10)
+		if err != nil {
+			t.Fatalf("failed to find images: %s", err)
+		}
+		if len(images) != 2 || images[0].ID != failing.ID || images[0].ThumbnailAttempts != 2 || images[1].ThumbnailAttempts != 0 {
+			t.Errorf("expected image %d with 2 attempts first, got %+v", failing.ID, images)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to begin transaction: %s", err)
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TrackingChannel is the name of PostgreSQL notification channel notified whenever the set of tracked
+// guilds or channels changes.
+const TrackingChannel = "tracking"
+
+// TrackedChannel is a channel whose messages are stored. GuildID is unset for channels tracked without
+// connection to Discord, and filled in once the bot resolves it.
+type TrackedChannel struct {
+	DiscordID Snowflake
+	GuildID   NullableSnowflake
+}
+
+// SeedTrackedGuild starts tracking guild with the specified Discord ID, unless tracking of it was
+// already started or stopped before. Returns true if tracking was started.
+func SeedTrackedGuild(ctx context.Context, tx pgx.Tx, ID Snowflake) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `insert into tracked_guild (discord_id, tracked, updated_at) values ($1, true, now()) on conflict do nothing`, []interface{}{ID})
+}
+
+// SeedTrackedChannel starts tracking channel with the specified Discord ID, unless tracking of it was
+// already started or stopped before. Returns true if tracking was started.
+func SeedTrackedChannel(ctx context.Context, tx pgx.Tx, ID Snowflake) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `insert into tracked_channel (discord_id, tracked, updated_at) values ($1, true, now()) on conflict do nothing`, []interface{}{ID})
+}
+
+// SetGuildTracked starts or stops tracking guild with the specified Discord ID.
+func SetGuildTracked(ctx context.Context, tx pgx.Tx, ID Snowflake, tracked bool) error {
+	_, err := queryUpdateDelete(ctx, tx, `insert into tracked_guild (discord_id, tracked, updated_at) values ($1, $2, now()) on conflict (discord_id) do update set tracked = excluded.tracked, updated_at = excluded.updated_at`, []interface{}{ID, tracked})
+	return err
+}
+
+// SetChannelTracked starts or stops tracking channel c.DiscordID, keeping previously known guild if
+// c.GuildID is unset.
+func SetChannelTracked(ctx context.Context, tx pgx.Tx, c *TrackedChannel, tracked bool) error {
+	_, err := queryUpdateDelete(ctx, tx, `insert into tracked_channel (discord_id, guild_discord_id, tracked, updated_at) values ($1, $2, $3, now()) on conflict (discord_id) do update set guild_discord_id = coalesce(excluded.guild_discord_id, tracked_channel.guild_discord_id), tracked = excluded.tracked, updated_at = excluded.updated_at`, []interface{}{c.DiscordID, c.GuildID, tracked})
+	return err
+}
+
+// UntrackGuildChannels stops tracking all channels of guild with the specified Discord ID.
+func UntrackGuildChannels(ctx context.Context, tx pgx.Tx, guildID Snowflake) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `update tracked_channel set tracked = false, updated_at = now() where guild_discord_id = $1 and tracked`, []interface{}{guildID})
+}
+
+// UpdateTrackedChannelGuild stores Discord ID of guild of tracked channel with the specified Discord ID.
+func UpdateTrackedChannelGuild(ctx context.Context, tx pgx.Tx, ID Snowflake, guildID Snowflake) error {
+	_, err := queryUpdateDelete(ctx, tx, `update tracked_channel set guild_discord_id = $2 where discord_id = $1`, []interface{}{ID, guildID})
+	return err
+}
+
+// FindTrackedGuilds returns Discord IDs of all tracked guilds.
+func FindTrackedGuilds(ctx context.Context, tx pgx.Tx) ([]Snowflake, error) {
+	g := make([]Snowflake, 0, 8)
+	q, err := tx.Query(ctx, `select discord_id from tracked_guild where tracked order by discord_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var ID Snowflake
+		if err := q.Scan(&ID); err != nil {
+			return nil, err
+		}
+
+		g = append(g, ID)
+	}
+
+	return g, q.Err()
+}
+
+// FindTrackedChannels returns all tracked channels.
+func FindTrackedChannels(ctx context.Context, tx pgx.Tx) ([]*TrackedChannel, error) {
+	c := make([]*TrackedChannel, 0, 16)
+	q, err := tx.Query(ctx, `select discord_id, guild_discord_id from tracked_channel where tracked order by discord_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		tc := &TrackedChannel{}
+		if err := q.Scan(&tc.DiscordID, &tc.GuildID); err != nil {
+			return nil, err
+		}
+
+		c = append(c, tc)
+	}
+
+	return c, q.Err()
+}
+
+// NotifyTrackingChanged notifies listeners of TrackingChannel once the transaction commits.
+func NotifyTrackingChanged(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `select pg_notify($1, '')`, TrackingChannel)
+	return err
+}
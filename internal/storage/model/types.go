@@ -25,3 +25,5 @@ type NullIdentifiableDiscordEntity struct {
 }
 
 type Ref = uint32
+
+type NullableTime = sql.NullTime
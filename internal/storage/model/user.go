@@ -2,7 +2,9 @@ package model
 
 import (
 	"context"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v4"
 )
 
@@ -21,3 +23,84 @@ func WrapUserID(ID string) *User {
 func FindOrCreateUser(ctx context.Context, tx pgx.Tx, u *User) error {
 	return query(ctx, tx, `with e as (insert into "user" (discord_id) values ($1) on conflict do nothing returning id) select id from e union select id from "user" where discord_id = $1`, []interface{}{u.DiscordID}, []interface{}{&u.ID})
 }
+
+// FindUser finds user with the specified Discord ID, leaving u.ID zero if the user is not known.
+func FindUser(ctx context.Context, tx pgx.Tx, u *User) error {
+	return query(ctx, tx, `select id from "user" where discord_id = $1`, []interface{}{u.DiscordID}, []interface{}{&u.ID})
+}
+
+// UserProfile is Discord profile of a user, where Avatar is an empty string for users with the default
+// avatar.
+type UserProfile struct {
+	Username      string
+	Discriminator string
+	Avatar        string
+}
+
+func WrapDiscordUser(u *discordgo.User) *UserProfile {
+	return &UserProfile{u.Username, u.Discriminator, u.Avatar}
+}
+
+// UserProfileRecord is a profile of a user as recorded at some point in time.
+type UserProfileRecord struct {
+	UserProfile
+	RecordedAt time.Time
+}
+
+// UpdateUserProfile stores profile of the specified user, recording it in history if it changed. Returns
+// true if the profile changed.
+func UpdateUserProfile(ctx context.Context, tx pgx.Tx, u *User, p *UserProfile) (bool, error) {
+	return queryUpdateDelete(ctx, tx, `with u as (update "user" set username = $2, discriminator = $3, avatar = nullif($4, ''), updated_at = now() where id = $1 and (username, discriminator, avatar) is distinct from ($2::varchar, $3::varchar, nullif($4::varchar, '')) returning id, username, discriminator, avatar) insert into user_history (user_id, username, discriminator, avatar, recorded_at) select id, username, discriminator, avatar, now() from u`, []interface{}{u.ID, p.Username, p.Discriminator, p.Avatar})
+}
+
+// FindUserProfile finds profile of the specified user, leaving p unchanged and returning false if it has
+// not been recorded yet.
+func FindUserProfile(ctx context.Context, tx pgx.Tx, u *User, p *UserProfile) (bool, error) {
+	var found bool
+	err := query(ctx, tx, `select true, username, discriminator, coalesce(avatar, '') from "user" where discord_id = $1 and username is not null`, []interface{}{u.DiscordID}, []interface{}{&found, &p.Username, &p.Discriminator, &p.Avatar})
+	return found, err
+}
+
+// FindUserProfiles finds profiles of users with the specified Discord IDs, keyed by Discord ID. Users
+// whose profile has not been recorded yet are omitted.
+func FindUserProfiles(ctx context.Context, tx pgx.Tx, users []Snowflake) (map[Snowflake]*UserProfile, error) {
+	profiles := make(map[Snowflake]*UserProfile, len(users))
+	q, err := tx.Query(ctx, `select discord_id, username, discriminator, coalesce(avatar, '') from "user" where discord_id = any($1) and username is not null`, users)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Snowflake
+		p := &UserProfile{}
+		if err := q.Scan(&id, &p.Username, &p.Discriminator, &p.Avatar); err != nil {
+			return nil, err
+		}
+
+		profiles[id] = p
+	}
+
+	return profiles, q.Err()
+}
+
+// FindUserProfileHistory returns all recorded profiles of the specified user, newest first.
+func FindUserProfileHistory(ctx context.Context, tx pgx.Tx, u *User) ([]*UserProfileRecord, error) {
+	h := make([]*UserProfileRecord, 0, 4)
+	q, err := tx.Query(ctx, `select h.username, h.discriminator, coalesce(h.avatar, ''), h.recorded_at from user_history h join "user" u on u.id = h.user_id where u.discord_id = $1 order by h.recorded_at desc, h.id desc`, u.DiscordID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		r := &UserProfileRecord{}
+		if err := q.Scan(&r.Username, &r.Discriminator, &r.Avatar, &r.RecordedAt); err != nil {
+			return nil, err
+		}
+
+		h = append(h, r)
+	}
+
+	return h, q.Err()
+}
@@ -17,7 +17,7 @@ func NewUserReaction() *UserReaction {
 }
 
 func CreateUserReaction(ctx context.Context, tx pgx.Tx, ur *UserReaction) error {
-	return query(ctx, tx, `insert into user_reaction (reaction_id, user_id) values ($1, $2) returning id`, []interface{}{ur.ReactionID, ur.UserID}, []interface{}{&ur.ID})
+	return query(ctx, tx, `insert into user_reaction (reaction_id, user_id) values ($1, $2) on conflict do nothing returning id`, []interface{}{ur.ReactionID, ur.UserID}, []interface{}{&ur.ID})
 }
 
 func DeleteUserReaction(ctx context.Context, tx pgx.Tx, ur *UserReaction) (bool, error) {
@@ -36,4 +36,48 @@ func CountUserReactions(ctx context.Context, tx pgx.Tx, p *Post) (uint32, error)
 	}
 
 	return count, nil
-}
\ No newline at end of file
+}
+
+// CountPostsUserReactions counts distinct users that reacted to each of the specified posts, keyed by post
+// ID. Posts without reactions are omitted.
+func CountPostsUserReactions(ctx context.Context, tx pgx.Tx, posts []*Post) (map[Ref]uint32, error) {
+	counts := make(map[Ref]uint32, len(posts))
+	q, err := tx.Query(ctx, `select r.post_id, count(distinct ur.user_id) from reaction r join user_reaction ur on r.id = ur.reaction_id where r.post_id = any($1) group by r.post_id`, postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Ref
+		var count uint32
+		if err := q.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+
+		counts[id] = count
+	}
+
+	return counts, q.Err()
+}
+
+// FindReactionUserIDs returns Discord IDs of users that reacted to the specified post with emoji.
+func FindReactionUserIDs(ctx context.Context, tx pgx.Tx, p *Post, em *Emoji) ([]Snowflake, error) {
+	ids := make([]Snowflake, 0, 8)
+	q, err := tx.Query(ctx, `select u.discord_id from reaction r join emoji e on e.id = r.emoji_id join user_reaction ur on r.id = ur.reaction_id join "user" u on u.id = ur.user_id where r.post_id = $1 and (e.discord_id = $2 or ($2 is null and e.discord_id is null and e.name = $3))`, p.ID, em.DiscordID, em.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.Close()
+	for q.Next() {
+		var id Snowflake
+		if err := q.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, q.Err()
+}
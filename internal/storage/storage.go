@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
@@ -20,8 +21,14 @@ func NewStorage(ctx context.Context, l *zap.SugaredLogger) *Storage {
 }
 
 func (s *Storage) Connect(dsn string) error {
-	var err error
-	s.pool, err = pgxpool.Connect(s.ctx, dsn)
+	conf, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+
+	conf.ConnConfig.Logger = queryLogger{}
+	conf.ConnConfig.LogLevel = pgx.LogLevelInfo
+	s.pool, err = pgxpool.ConnectConfig(s.ctx, conf)
 	return err
 }
 
@@ -29,10 +36,42 @@ func (s *Storage) Begin(ctx context.Context, fn func(pgx.Tx) error) error {
 	return s.pool.BeginFunc(ctx, fn)
 }
 
+// BeginReadOnly runs fn in a read-only repeatable read transaction, so that all queries made by fn see
+// the same snapshot of the database.
+func (s *Storage) BeginReadOnly(ctx context.Context, fn func(pgx.Tx) error) error {
+	return s.pool.BeginTxFunc(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, fn)
+}
+
+// Ping checks if the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
 func (s *Storage) QueryFunc(ctx context.Context, sql string, args []interface{}, scans []interface{}) (pgconn.CommandTag, error) {
 	return s.pool.QueryFunc(ctx, sql, args, scans, func(pgx.QueryFuncRow) error { return nil })
 }
 
+// Listen listens on the specified PostgreSQL notification channel, calling fn for every notification
+// received, until ctx is done or the connection fails.
+func (s *Storage) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "listen "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		fn(n.Payload)
+	}
+}
+
 func (s *Storage) Close() error {
 	s.pool.Close()
 	return nil
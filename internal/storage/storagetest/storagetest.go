@@ -0,0 +1,84 @@
+// Package storagetest provides storage backed by PostgreSQL for tests. The database is specified by
+// MONICU_TEST_POSTGRES_DSN environment variable; tests using it are skipped if it is not set.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/storage"
+)
+
+// DSNEnv is the environment variable holding DSN of the database used by tests.
+const DSNEnv = "MONICU_TEST_POSTGRES_DSN"
+
+// ownerRegexp matches statements changing owner of tables in schema.sql, which needs the role to exist.
+var ownerRegexp = regexp.MustCompile(`(?m)^alter table \S+\s+owner to \w+;$`)
+
+// Open returns storage with the schema created in a new PostgreSQL schema, which is dropped when the test
+// finishes, so that tests do not see each other's data. Skips the test if no database is configured.
+func Open(t *testing.T) *storage.Storage {
+	t.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", DSNEnv)
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(ctx) })
+
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := conn.Exec(ctx, "create schema "+name); err != nil {
+		t.Fatalf("failed to create schema: %s", err)
+	}
+	t.Cleanup(func() {
+		if _, err := conn.Exec(ctx, "drop schema "+name+" cascade"); err != nil {
+			t.Errorf("failed to drop schema: %s", err)
+		}
+	})
+
+	sql, err := os.ReadFile(schemaPath())
+	if err != nil {
+		t.Fatalf("failed to read schema: %s", err)
+	}
+	if _, err := conn.Exec(ctx, "set search_path to "+name+";\n"+ownerRegexp.ReplaceAllString(string(sql), "")); err != nil {
+		t.Fatalf("failed to create tables: %s", err)
+	}
+
+	s := storage.NewStorage(ctx, zap.NewNop().Sugar())
+	if err := s.Connect(withSearchPath(dsn, name)); err != nil {
+		t.Fatalf("failed to connect storage: %s", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// schemaPath returns path of sql/schema.sql in the repository.
+func schemaPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "sql", "schema.sql")
+}
+
+// withSearchPath returns dsn, which is either an URL or key/value pairs, with search_path set to schema.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
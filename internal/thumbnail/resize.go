@@ -0,0 +1,53 @@
+package thumbnail
+
+import (
+	"image"
+	"image/color"
+)
+
+// resize scales src down to the specified width preserving aspect ratio. Every destination pixel is an
+// average of the source pixels it covers (box filter), which is good enough for downscaling and needs
+// nothing but the standard library.
+func resize(src image.Image, width int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	height := sh * width / sw
+	if height == 0 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0, y1 := span(dy, height, sh)
+		for dx := 0; dx < width; dx++ {
+			x0, x1 := span(dx, width, sw)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := src.At(sb.Min.X+x, sb.Min.Y+y).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+
+	return dst
+}
+
+// span returns the half-open range of source coordinates covered by destination coordinate i.
+func span(i, dstLen, srcLen int) (int, int) {
+	from, to := i*srcLen/dstLen, (i+1)*srcLen/dstLen
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
@@ -0,0 +1,373 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/storage"
+	"pkg.mon.icu/monicu/internal/storage/model"
+)
+
+const (
+	batchSize    = 16
+	fetchTimeout = 30 * time.Second
+	jpegQuality  = 85
+
+	// maxImageBytes and maxImagePixels bound images that are decoded, which takes 4 bytes per pixel.
+	maxImageBytes  = 64 << 20
+	maxImagePixels = 50_000_000
+
+	// retryMinDelay and retryMaxDelay bound the exponential backoff between attempts to create thumbnails
+	// of an image failing transiently, which is given up on after maxAttempts.
+	retryMinDelay = time.Minute
+	retryMaxDelay = 24 * time.Hour
+	maxAttempts   = 10
+
+	// sweepInterval is how often files of thumbnails deleted along with their images are removed, which
+	// are checked against the database sweepBatchSize files at a time.
+	sweepInterval  = time.Hour
+	sweepBatchSize = 1000
+)
+
+// errPermanent marks failures that will not go away on retry, such as a missing or undecodable image.
+var errPermanent = errors.New("permanent failure")
+
+type Config struct {
+	Dir      string
+	Widths   []uint32
+	Interval time.Duration
+}
+
+func NewConfig(dir string, widths []uint32, interval time.Duration) *Config {
+	return &Config{Dir: dir, Widths: widths, Interval: interval}
+}
+
+// Thumbnailer periodically picks up images that have no thumbnails yet, fetches each of them once
+// and stores downscaled copies for every configured width in a local directory.
+type Thumbnailer struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	logger  *zap.SugaredLogger
+	storage *storage.Storage
+	config  *Config
+	client  *http.Client
+	done    chan struct{}
+}
+
+func NewThumbnailer(ctx context.Context, log *zap.SugaredLogger, store *storage.Storage, config *Config) *Thumbnailer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Thumbnailer{
+		ctx:     ctx,
+		cancel:  cancel,
+		logger:  log,
+		storage: store,
+		config:  config,
+		client:  &http.Client{Timeout: fetchTimeout},
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *Thumbnailer) Start() error {
+	if t.config.Dir == "" || t.config.Interval <= 0 {
+		return errors.New("thumbnail directory and a positive interval have to be configured")
+	}
+	if err := os.MkdirAll(t.config.Dir, 0o755); err != nil {
+		return fmt.Errorf("couldn't create thumbnail directory: %w", err)
+	}
+
+	go t.run()
+	return nil
+}
+
+func (t *Thumbnailer) Close() error {
+	t.cancel()
+	<-t.done
+	return nil
+}
+
+func (t *Thumbnailer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+	var swept time.Time
+	for {
+		t.processPending()
+		if time.Since(swept) >= sweepInterval {
+			t.sweep()
+			swept = time.Now()
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processPending processes batches of images without thumbnails until none are left. Images failing
+// transiently are retried later with backoff (see delay), so that they do not hold up the rest.
+func (t *Thumbnailer) processPending() {
+	for t.ctx.Err() == nil {
+		var images []*model.Image
+		if err := t.storage.Begin(t.ctx, func(tx pgx.Tx) error {
+			var err error
+			images, err = model.FindImagesWithoutThumbnails(t.ctx, tx, batchSize)
+			return err
+		}); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				t.logger.Errorf("Failed to find images without thumbnails: %s.", err)
+			}
+			return
+		}
+
+		if len(images) == 0 {
+			return
+		}
+
+		for _, im := range images {
+			err := t.process(im)
+			switch {
+			case err == nil:
+			case errors.Is(err, context.Canceled):
+				return
+			case errors.Is(err, errPermanent):
+				t.logger.Warnf("Couldn't create thumbnails for image %d: %s.", im.ID, err)
+			default:
+				if derr := t.delay(im, err); derr != nil {
+					// Likely the database is unavailable, so the rest is left for the next tick
+					if !errors.Is(derr, context.Canceled) {
+						t.logger.Errorf("Failed to record failure of image %d: %s.", im.ID, derr)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// sweep removes thumbnail files that no longer belong to a stored thumbnail, such as ones of images
+// deleted along with their posts. It runs in the same goroutine as processPending, so files being written
+// are never removed before their thumbnails are stored.
+func (t *Thumbnailer) sweep() {
+	entries, err := os.ReadDir(t.config.Dir)
+	if err != nil {
+		t.logger.Errorf("Failed to list thumbnail files: %s.", err)
+		return
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		// Temporary files of writeFile start with a dot
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+
+	var removed int
+	for len(names) > 0 && t.ctx.Err() == nil {
+		batch := names
+		if len(batch) > sweepBatchSize {
+			batch = batch[:sweepBatchSize]
+		}
+		names = names[len(batch):]
+
+		var stored map[string]bool
+		if err := t.storage.BeginReadOnly(t.ctx, func(tx pgx.Tx) error {
+			var err error
+			stored, err = model.FindThumbnailPaths(t.ctx, tx, batch)
+			return err
+		}); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				t.logger.Errorf("Failed to find thumbnails: %s.", err)
+			}
+			return
+		}
+
+		for _, name := range batch {
+			if stored[name] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(t.config.Dir, name)); err != nil && !os.IsNotExist(err) {
+				t.logger.Errorf("Failed to remove thumbnail file %s: %s.", name, err)
+				continue
+			}
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		t.logger.Infof("Removed %d thumbnail files of deleted images.", removed)
+	}
+}
+
+// retryDelay returns delay before the next attempt to create thumbnails of an image that failed the
+// specified number of times.
+func retryDelay(attempts int32) time.Duration {
+	delay := retryMinDelay
+	for i := int32(1); i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// delay schedules another attempt to create thumbnails of image that failed transiently with cause, or
+// marks it as processed once it failed maxAttempts times.
+func (t *Thumbnailer) delay(im *model.Image, cause error) error {
+	attempts := im.ThumbnailAttempts + 1
+	if attempts >= maxAttempts {
+		t.logger.Warnf("Couldn't create thumbnails for image %d after %d attempts: %s.", im.ID, attempts, cause)
+		return t.storage.Begin(t.ctx, func(tx pgx.Tx) error {
+			_, err := model.MarkImageThumbnailed(t.ctx, tx, im)
+			return err
+		})
+	}
+
+	retryAt := time.Now().Add(retryDelay(attempts))
+	t.logger.Warnf("Failed to create thumbnails for image %d (attempt %d of %d), retrying at %s: %s.", im.ID, attempts, maxAttempts, retryAt.Format(time.RFC3339), cause)
+	return t.storage.Begin(t.ctx, func(tx pgx.Tx) error {
+		_, err := model.DelayImageThumbnails(t.ctx, tx, im, retryAt)
+		return err
+	})
+}
+
+// process creates thumbnails of a single image. Image is marked as processed unless a transient error
+// occurred.
+func (t *Thumbnailer) process(im *model.Image) error {
+	t.logger.Debugf("Creating thumbnails for image %d.", im.ID)
+	src, format, err := t.fetch(im.URL)
+
+	var thumbs []*model.Thumbnail
+	if err == nil {
+		thumbs, err = t.write(im, src, format)
+	}
+	if err != nil && !errors.Is(err, errPermanent) {
+		return err
+	}
+
+	if terr := t.storage.Begin(t.ctx, func(tx pgx.Tx) error {
+		for _, th := range thumbs {
+			if err := model.CreateThumbnail(t.ctx, tx, th); err != nil {
+				return fmt.Errorf("failed to create thumbnail: %w", err)
+			}
+		}
+		if _, err := model.MarkImageThumbnailed(t.ctx, tx, im); err != nil {
+			return fmt.Errorf("failed to mark image as thumbnailed: %w", err)
+		}
+		return nil
+	}); terr != nil {
+		return terr
+	}
+
+	return err
+}
+
+func (t *Thumbnailer) fetch(url string) (image.Image, string, error) {
+	req, err := http.NewRequestWithContext(t.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", errPermanent, err)
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests:
+		return nil, "", fmt.Errorf("unexpected status %s", res.Status)
+	case res.StatusCode >= 300:
+		return nil, "", fmt.Errorf("%w: unexpected status %s", errPermanent, res.Status)
+	}
+
+	return decode(io.LimitReader(res.Body, maxImageBytes))
+}
+
+// decode decodes image, checking its dimensions first so that huge images are not decoded.
+func decode(r io.Reader) (image.Image, string, error) {
+	// Bytes read to decode the header are decoded again along with the rest
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: couldn't decode image: %s", errPermanent, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, "", fmt.Errorf("%w: unsupported image dimensions %dx%d", errPermanent, cfg.Width, cfg.Height)
+	}
+
+	src, format, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: couldn't decode image: %s", errPermanent, err)
+	}
+
+	return src, format, nil
+}
+
+// write stores a thumbnail for every configured width narrower than the source image. JPEG sources
+// produce JPEG thumbnails, everything else is stored as PNG.
+func (t *Thumbnailer) write(im *model.Image, src image.Image, format string) ([]*model.Thumbnail, error) {
+	ext := "png"
+	if format == "jpeg" {
+		ext = "jpg"
+	}
+
+	thumbs := make([]*model.Thumbnail, 0, len(t.config.Widths))
+	for _, w := range t.config.Widths {
+		if int(w) >= src.Bounds().Dx() {
+			continue
+		}
+
+		dst := resize(src, int(w))
+		name := fmt.Sprintf("%d_%d.%s", im.ID, w, ext)
+		if err := writeFile(filepath.Join(t.config.Dir, name), func(out io.Writer) error {
+			if ext == "jpg" {
+				return jpeg.Encode(out, dst, &jpeg.Options{Quality: jpegQuality})
+			}
+			return png.Encode(out, dst)
+		}); err != nil {
+			return nil, fmt.Errorf("couldn't write thumbnail: %w", err)
+		}
+
+		thumbs = append(thumbs, model.NewThumbnail(0, im.ID, w, uint32(dst.Bounds().Dy()), name))
+	}
+
+	return thumbs, nil
+}
+
+// writeFile writes a file atomically by encoding into a temporary file and renaming it into place.
+func writeFile(path string, encode func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := encode(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
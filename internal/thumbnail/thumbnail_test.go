@@ -0,0 +1,123 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+	"pkg.mon.icu/monicu/internal/storage/model"
+	"pkg.mon.icu/monicu/internal/storage/storagetest"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode image: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	src, format, err := decode(bytes.NewReader(encodePNG(t, 40, 30)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if format != "png" || src.Bounds().Dx() != 40 || src.Bounds().Dy() != 30 {
+		t.Errorf("expected 40x30 png, got %dx%d %s", src.Bounds().Dx(), src.Bounds().Dy(), format)
+	}
+
+	// Header claiming dimensions too large to decode, followed by data of a small image
+	huge := encodePNG(t, 1, 1)
+	binary.BigEndian.PutUint32(huge[16:], 100000)
+	binary.BigEndian.PutUint32(huge[20:], 100000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+	if _, _, err := decode(bytes.NewReader(huge)); !errors.Is(err, errPermanent) {
+		t.Errorf("expected permanent error for huge image, got %v", err)
+	}
+
+	if _, _, err := decode(bytes.NewReader([]byte("not an image"))); !errors.Is(err, errPermanent) {
+		t.Errorf("expected permanent error for invalid image, got %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{12, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	} {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("attempt %d: expected delay %s, got %s", tt.attempts, tt.want, got)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s := storagetest.Open(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if err := s.Begin(ctx, func(tx pgx.Tx) error {
+		g, u := model.NewGuild(0, 1), model.NewUser(0, 3)
+		if err := model.FindOrCreateGuild(ctx, tx, g); err != nil {
+			return err
+		}
+		if err := model.FindOrCreateUser(ctx, tx, u); err != nil {
+			return err
+		}
+		ch := model.WrapChannelID("2")
+		ch.GuildID = g.ID
+		if err := model.FindOrCreateChannel(ctx, tx, ch); err != nil {
+			return err
+		}
+		p := model.NewPost(0, 10, ch.ID, u.ID, "post")
+		if err := model.CreatePost(ctx, tx, p); err != nil {
+			return err
+		}
+		im := model.NewImage(0, p.ID, "https://cdn.example.com/1.png", 640, 480, 1024)
+		if err := model.CreateImage(ctx, tx, im); err != nil {
+			return err
+		}
+		return model.CreateThumbnail(ctx, tx, model.NewThumbnail(0, im.ID, 320, 240, "kept.png"))
+	}); err != nil {
+		t.Fatalf("failed to create thumbnail: %s", err)
+	}
+	for _, name := range []string{"kept.png", "deleted.png", ".thumb-1"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	th := NewThumbnailer(ctx, zap.NewNop().Sugar(), s, NewConfig(dir, []uint32{320}, time.Minute))
+	th.sweep()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list files: %s", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{".thumb-1", "kept.png"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected files %v, got %v", want, names)
+	}
+}
@@ -65,7 +65,9 @@ create table if not exists post
         constraint post_user_id_fk
             references "user"
             on update cascade on delete cascade,
-    message    varchar(2000) not null
+    message    varchar(2000) not null,
+    created_at timestamp with time zone,
+    edited_at  timestamp with time zone
 );
 
 alter table post
@@ -89,7 +91,8 @@ create table if not exists image
     url     text    not null,
     width   integer not null,
     height  integer not null,
-    size    bigint  not null
+    size    bigint  not null,
+    thumbnailed_at timestamp with time zone
 );
 
 alter table image
@@ -168,3 +171,236 @@ create unique index if not exists user_reaction_id_uindex
 create unique index if not exists user_reaction_reaction_id_user_id_uindex
     on user_reaction (reaction_id, user_id);
 
+
+
+alter table image
+    add column if not exists thumbnailed_at timestamp with time zone;
+
+create table if not exists thumbnail
+(
+    id       serial
+        constraint thumbnail_pk
+            primary key,
+    image_id integer not null
+        constraint thumbnail_image_id_fk
+            references image
+            on update cascade on delete cascade,
+    width    integer not null,
+    height   integer not null,
+    path     text    not null
+);
+
+alter table thumbnail
+    owner to monicu;
+
+create unique index if not exists thumbnail_id_uindex
+    on thumbnail (id);
+
+create unique index if not exists thumbnail_image_id_width_uindex
+    on thumbnail (image_id, width);
+
+create table if not exists channel_sync
+(
+    id          serial
+        constraint channel_sync_pk
+            primary key,
+    channel_id  integer                  not null
+        constraint channel_sync_channel_id_fk
+            references channel (id)
+            on update cascade on delete cascade,
+    direction   varchar(16)              not null,
+    cursor      bigint,
+    started_at  timestamp with time zone not null,
+    finished_at timestamp with time zone,
+    error       text
+);
+
+alter table channel_sync
+    owner to monicu;
+
+create unique index if not exists channel_sync_id_uindex
+    on channel_sync (id);
+
+create unique index if not exists channel_sync_channel_id_uindex
+    on channel_sync (channel_id);
+
+create table if not exists tracked_guild
+(
+    id         serial
+        constraint tracked_guild_pk
+            primary key,
+    discord_id bigint                   not null,
+    tracked    boolean                  not null,
+    updated_at timestamp with time zone not null
+);
+
+alter table tracked_guild
+    owner to monicu;
+
+create unique index if not exists tracked_guild_id_uindex
+    on tracked_guild (id);
+
+create unique index if not exists tracked_guild_discord_id_uindex
+    on tracked_guild (discord_id);
+
+create table if not exists tracked_channel
+(
+    id               serial
+        constraint tracked_channel_pk
+            primary key,
+    discord_id       bigint                   not null,
+    guild_discord_id bigint,
+    tracked          boolean                  not null,
+    updated_at       timestamp with time zone not null
+);
+
+alter table tracked_channel
+    owner to monicu;
+
+create unique index if not exists tracked_channel_id_uindex
+    on tracked_channel (id);
+
+create unique index if not exists tracked_channel_discord_id_uindex
+    on tracked_channel (discord_id);
+
+alter table channel
+    add column if not exists archived_at timestamp with time zone;
+
+alter table post
+    add column if not exists created_at timestamp with time zone;
+
+alter table post
+    add column if not exists edited_at timestamp with time zone;
+
+create index if not exists post_created_at_index
+    on post (created_at);
+
+alter table "user"
+    add column if not exists username varchar(32);
+
+alter table "user"
+    add column if not exists discriminator varchar(4);
+
+alter table "user"
+    add column if not exists avatar varchar(64);
+
+alter table "user"
+    add column if not exists updated_at timestamp with time zone;
+
+create table if not exists user_history
+(
+    id            serial
+        constraint user_history_pk
+            primary key,
+    user_id       integer                  not null
+        constraint user_history_user_id_fk
+            references "user"
+            on update cascade on delete cascade,
+    username      varchar(32)              not null,
+    discriminator varchar(4)               not null,
+    avatar        varchar(64),
+    recorded_at   timestamp with time zone not null
+);
+
+alter table user_history
+    owner to monicu;
+
+create unique index if not exists user_history_id_uindex
+    on user_history (id);
+
+create index if not exists user_history_user_id_index
+    on user_history (user_id);
+
+create table if not exists member
+(
+    id         serial
+        constraint member_pk
+            primary key,
+    user_id    integer                  not null
+        constraint member_user_id_fk
+            references "user"
+            on update cascade on delete cascade,
+    guild_id   integer                  not null
+        constraint member_guild_id_fk
+            references guild
+            on update cascade on delete cascade,
+    nickname   varchar(32),
+    updated_at timestamp with time zone not null
+);
+
+alter table member
+    owner to monicu;
+
+create unique index if not exists member_id_uindex
+    on member (id);
+
+create unique index if not exists member_user_id_guild_id_uindex
+    on member (user_id, guild_id);
+
+create table if not exists member_history
+(
+    id          serial
+        constraint member_history_pk
+            primary key,
+    member_id   integer                  not null
+        constraint member_history_member_id_fk
+            references member
+            on update cascade on delete cascade,
+    nickname    varchar(32),
+    recorded_at timestamp with time zone not null
+);
+
+alter table member_history
+    owner to monicu;
+
+create unique index if not exists member_history_id_uindex
+    on member_history (id);
+
+create index if not exists member_history_member_id_index
+    on member_history (member_id);
+
+alter table guild
+    add column if not exists name varchar(100);
+
+alter table channel
+    add column if not exists name varchar(100);
+
+alter table channel
+    add column if not exists topic varchar(1024);
+
+alter table channel
+    add column if not exists nsfw boolean;
+
+alter table channel
+    add column if not exists position integer;
+
+alter table channel
+    add column if not exists parent_discord_id bigint;
+
+create table if not exists failed_event
+(
+    id              serial
+        constraint failed_event_pk
+            primary key,
+    type            varchar(32)              not null,
+    payload         jsonb                    not null,
+    error           text                     not null,
+    attempts        integer                  not null,
+    failed_at       timestamp with time zone not null,
+    next_attempt_at timestamp with time zone not null
+);
+
+alter table failed_event
+    owner to monicu;
+
+create unique index if not exists failed_event_id_uindex
+    on failed_event (id);
+
+create index if not exists failed_event_next_attempt_at_index
+    on failed_event (next_attempt_at);
+
+alter table image
+    add column if not exists thumbnail_attempts integer default 0 not null;
+
+alter table image
+    add column if not exists thumbnail_retry_at timestamp with time zone;